@@ -0,0 +1,78 @@
+package ttlock
+
+import (
+	"context"
+	"sort"
+)
+
+// LowBatteryLock is a lock reported by BatteryReport.
+type LowBatteryLock struct {
+	Lock        Lock               `json:"lock"`
+	Accessories []AccessoryBattery `json:"accessories,omitempty"` // only filled when accessories are requested
+}
+
+// BatteryGroup holds the low-battery locks of one lock group.
+type BatteryGroup struct {
+	GroupName string           `json:"groupName"`
+	Locks     []LowBatteryLock `json:"locks"`
+}
+
+// BatteryReportResult is the result of BatteryReport.
+type BatteryReportResult struct {
+	Threshold int            `json:"threshold"`
+	Scanned   int            `json:"scanned"` // number of locks checked
+	Low       int            `json:"low"`     // number of locks reported
+	Groups    []BatteryGroup `json:"groups"`  // ordered by group name
+}
+
+// BatteryReport scans all locks and returns those whose battery is below
+// threshold percent, grouped by GroupName and ordered by battery level.
+// If accessories is set, locks supporting LockFeatureAccessoryBattery are
+// enriched with their accessory levels and are also reported when any
+// accessory is below threshold.
+func (c *Client) BatteryReport(ctx context.Context, threshold int, accessories bool) (*BatteryReportResult, error) {
+	return BatteryReport(ctx, c, threshold, accessories)
+}
+
+// BatteryReport scans the locks of s, like Client.BatteryReport.
+func BatteryReport(ctx context.Context, s LockService, threshold int, accessories bool) (*BatteryReportResult, error) {
+	report := &BatteryReportResult{Threshold: threshold}
+	groups := make(map[string][]LowBatteryLock)
+
+	for lock, err := range Locks(ctx, s, LockFilter{}) {
+		if err != nil {
+			return nil, err
+		}
+		report.Scanned++
+
+		low := lock.ElectricQuantity < threshold
+		entry := LowBatteryLock{Lock: lock}
+		if accessories && lock.SupportsFeature(LockFeatureAccessoryBattery) {
+			resp, err := s.GetAccessoryBattery(lock.LockID)
+			if err != nil {
+				return nil, err
+			}
+			entry.Accessories = resp.List
+			for _, a := range resp.List {
+				if a.ElectricQuantity < threshold {
+					low = true
+				}
+			}
+		}
+
+		if low {
+			groups[lock.GroupName] = append(groups[lock.GroupName], entry)
+			report.Low++
+		}
+	}
+
+	for name, locks := range groups {
+		sort.SliceStable(locks, func(i, j int) bool {
+			return locks[i].Lock.ElectricQuantity < locks[j].Lock.ElectricQuantity
+		})
+		report.Groups = append(report.Groups, BatteryGroup{GroupName: name, Locks: locks})
+	}
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].GroupName < report.Groups[j].GroupName })
+
+	return report, nil
+}
@@ -0,0 +1,287 @@
+package ttlock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LockSnapshot is the cached state of one lock.
+type LockSnapshot struct {
+	Lock   Lock       `json:"lock"`
+	Detail LockDetail `json:"detail"`
+}
+
+// LockEventType identifies the kind of change found by LockCache.Refresh.
+type LockEventType string
+
+const (
+	LockAdded       LockEventType = "added"
+	LockRemoved     LockEventType = "removed"
+	BatteryChanged  LockEventType = "battery"
+	FirmwareChanged LockEventType = "firmware"
+	SettingsChanged LockEventType = "settings"
+)
+
+// LockEvent describes a change of one lock between two snapshots.
+type LockEvent struct {
+	Type   LockEventType `json:"type"`
+	LockID int           `json:"lockId"`
+	Old    *LockSnapshot `json:"old,omitempty"`    // nil for LockAdded
+	New    *LockSnapshot `json:"new,omitempty"`    // nil for LockRemoved
+	Fields []string      `json:"fields,omitempty"` // JSON names of the changed fields
+}
+
+// lockCacheFile is the on-disk format of a LockCache.
+type lockCacheFile struct {
+	UpdatedAt time.Time      `json:"updatedAt"`
+	Locks     []LockSnapshot `json:"locks"`
+}
+
+// LockCache keeps a snapshot of the account's locks and their details on disk
+// so that readers do not have to call the API on every access.
+// The lock secrets Lock.LockData and LockDetail.NoKeyPwd are left out of the
+// snapshots unless the cache is created with WithLockSecrets.
+type LockCache struct {
+	client  LockService
+	path    string
+	secrets bool
+
+	mu        sync.RWMutex
+	locks     map[int]LockSnapshot
+	updatedAt time.Time
+}
+
+// LockCacheOption configures a LockCache.
+type LockCacheOption func(*LockCache)
+
+// WithLockSecrets keeps the lock data and the admin passcode in the snapshots
+// and thus in the cache file. Anyone who can read the file can open the locks.
+func WithLockSecrets() LockCacheOption {
+	return func(lc *LockCache) {
+		lc.secrets = true
+	}
+}
+
+// NewLockCache creates a cache of the locks of s stored at path, loading the
+// previous snapshot if the file exists. Call Refresh to populate an empty cache.
+func NewLockCache(s LockService, path string, opts ...LockCacheOption) (*LockCache, error) {
+	lc := &LockCache{
+		client: s,
+		path:   path,
+		locks:  make(map[int]LockSnapshot),
+	}
+	for _, opt := range opts {
+		opt(lc)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock cache: %w", err)
+	}
+
+	var f lockCacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to decode lock cache: %w", err)
+	}
+	for _, s := range f.Locks {
+		lc.locks[s.Lock.LockID] = lc.snapshot(s.Lock, s.Detail)
+	}
+	lc.updatedAt = f.UpdatedAt
+	return lc, nil
+}
+
+// UpdatedAt returns the time of the last successful refresh.
+func (lc *LockCache) UpdatedAt() time.Time {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return lc.updatedAt
+}
+
+// Get returns the cached snapshot of a lock.
+func (lc *LockCache) Get(lockID int) (LockSnapshot, bool) {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	s, ok := lc.locks[lockID]
+	return s, ok
+}
+
+// Locks returns all cached snapshots ordered by lock ID.
+func (lc *LockCache) Locks() []LockSnapshot {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return sortedSnapshots(lc.locks)
+}
+
+// snapshot returns the snapshot of a lock, without its secrets unless the
+// cache keeps them
+func (lc *LockCache) snapshot(lock Lock, detail LockDetail) LockSnapshot {
+	if !lc.secrets {
+		lock.LockData = ""
+		detail.NoKeyPwd = ""
+	}
+	return LockSnapshot{Lock: lock, Detail: detail}
+}
+
+func sortedSnapshots(m map[int]LockSnapshot) []LockSnapshot {
+	list := make([]LockSnapshot, 0, len(m))
+	for _, s := range m {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Lock.LockID < list[j].Lock.LockID })
+	return list
+}
+
+// Refresh fetches all locks and their details, stores the new snapshot on disk
+// and returns the changes since the previous snapshot.
+// On error the cache keeps its previous content.
+func (lc *LockCache) Refresh() ([]LockEvent, error) {
+	fresh := make(map[int]LockSnapshot)
+	for lock, err := range Locks(context.Background(), lc.client, LockFilter{}) {
+		if err != nil {
+			return nil, err
+		}
+		detail, err := lc.client.GetLockDetail(lock.LockID)
+		if err != nil {
+			return nil, err
+		}
+		fresh[lock.LockID] = lc.snapshot(lock, *detail)
+	}
+	now := time.Now()
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if err := lc.save(fresh, now); err != nil {
+		return nil, err
+	}
+	events := diffSnapshots(lc.locks, fresh)
+	lc.locks = fresh
+	lc.updatedAt = now
+	return events, nil
+}
+
+// save writes the snapshot atomically via a temporary file, readable only by
+// the owner.
+func (lc *LockCache) save(locks map[int]LockSnapshot, updatedAt time.Time) error {
+	data, err := json.MarshalIndent(lockCacheFile{UpdatedAt: updatedAt, Locks: sortedSnapshots(locks)}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(lc.path), filepath.Base(lc.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write lock cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write lock cache: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write lock cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write lock cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), lc.path); err != nil {
+		return fmt.Errorf("failed to write lock cache: %w", err)
+	}
+	return nil
+}
+
+// Run refreshes the cache once right away and then every interval until ctx
+// is done, passing the result of each refresh to handle.
+func (lc *LockCache) Run(ctx context.Context, interval time.Duration, handle func([]LockEvent, error)) {
+	if ctx.Err() != nil {
+		return
+	}
+	handle(lc.Refresh())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			handle(lc.Refresh())
+		}
+	}
+}
+
+// diffSnapshots compares two snapshots and returns the events ordered by lock ID.
+func diffSnapshots(old, fresh map[int]LockSnapshot) []LockEvent {
+	var events []LockEvent
+
+	for _, n := range sortedSnapshots(fresh) {
+		o, ok := old[n.Lock.LockID]
+		if !ok {
+			events = append(events, LockEvent{Type: LockAdded, LockID: n.Lock.LockID, New: &n})
+			continue
+		}
+
+		if o.Detail.ElectricQuantity != n.Detail.ElectricQuantity {
+			events = append(events, LockEvent{Type: BatteryChanged, LockID: n.Lock.LockID, Old: &o, New: &n, Fields: []string{"electricQuantity"}})
+		}
+
+		var firmware []string
+		if o.Detail.ModelNum != n.Detail.ModelNum {
+			firmware = append(firmware, "modelNum")
+		}
+		if o.Detail.HardwareRevision != n.Detail.HardwareRevision {
+			firmware = append(firmware, "hardwareRevision")
+		}
+		if o.Detail.FirmwareRevision != n.Detail.FirmwareRevision {
+			firmware = append(firmware, "firmwareRevision")
+		}
+		if len(firmware) > 0 {
+			events = append(events, LockEvent{Type: FirmwareChanged, LockID: n.Lock.LockID, Old: &o, New: &n, Fields: firmware})
+		}
+
+		if settings := changedSettings(&o.Detail, &n.Detail); len(settings) > 0 {
+			events = append(events, LockEvent{Type: SettingsChanged, LockID: n.Lock.LockID, Old: &o, New: &n, Fields: settings})
+		}
+	}
+
+	for _, o := range sortedSnapshots(old) {
+		if _, ok := fresh[o.Lock.LockID]; !ok {
+			events = append(events, LockEvent{Type: LockRemoved, LockID: o.Lock.LockID, Old: &o})
+		}
+	}
+
+	return events
+}
+
+// changedSettings returns the JSON names of the user-configurable fields that differ.
+func changedSettings(o, n *LockDetail) []string {
+	var fields []string
+	check := func(name string, changed bool) {
+		if changed {
+			fields = append(fields, name)
+		}
+	}
+	check("lockAlias", o.LockAlias != n.LockAlias)
+	check("timezoneRawOffset", o.TimezoneRawOffset != n.TimezoneRawOffset)
+	check("autoLockTime", o.AutoLockTime != n.AutoLockTime)
+	check("lockSound", o.LockSound != n.LockSound)
+	check("privacyLock", o.PrivacyLock != n.PrivacyLock)
+	check("tamperAlert", o.TamperAlert != n.TamperAlert)
+	check("resetButton", o.ResetButton != n.ResetButton)
+	check("openDirection", o.OpenDirection != n.OpenDirection)
+	check("passageMode", o.PassageMode != n.PassageMode)
+	check("passageModeAutoUnlock", o.PassageModeAutoUnlock != n.PassageModeAutoUnlock)
+	return fields
+}
@@ -0,0 +1,161 @@
+package ttlock_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/immofon/ttlock"
+	"github.com/immofon/ttlock/ttlocktest"
+)
+
+func TestLockCacheLeavesOutSecrets(t *testing.T) {
+	srv, c := newTestServer(t)
+	lock := passcodeLock("Front")
+	lock.LockData = "secret-lock-data"
+	lock.Detail.NoKeyPwd = "987654"
+	lockID := srv.AddLock(lock)
+
+	for _, keep := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "locks.json")
+		var opts []ttlock.LockCacheOption
+		if keep {
+			opts = append(opts, ttlock.WithLockSecrets())
+		}
+		cache, err := ttlock.NewLockCache(c, path, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := cache.Refresh()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].Type != ttlock.LockAdded {
+			t.Errorf("events %+v", events)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Errorf("cache file mode %v, want 0600", perm)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snap, _ := cache.Get(lockID)
+		stored := strings.Contains(string(data), "secret-lock-data") && strings.Contains(string(data), "987654")
+		cached := snap.Lock.LockData != "" && snap.Detail.NoKeyPwd != ""
+		if stored != keep || cached != keep {
+			t.Errorf("WithLockSecrets %v: secrets in file %v, in snapshot %v", keep, stored, cached)
+		}
+	}
+}
+
+func TestLockCacheEvents(t *testing.T) {
+	srv, c := newTestServer(t)
+	front := srv.AddLock(passcodeLock("Front"))
+	back := srv.AddLock(passcodeLock("Back"))
+	cache, err := ttlock.NewLockCache(c, filepath.Join(t.TempDir(), "locks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		update func()
+		want   ttlock.LockEventType
+		lockID int
+		fields []string
+	}{
+		{"battery", func() {
+			srv.UpdateLock(front, func(l *ttlocktest.Lock) { l.Detail.ElectricQuantity = 15 })
+		}, ttlock.BatteryChanged, front, []string{"electricQuantity"}},
+		{"firmware", func() {
+			srv.UpdateLock(front, func(l *ttlocktest.Lock) {
+				l.Detail.HardwareRevision = "1.1"
+				l.Detail.FirmwareRevision = "6.0.2"
+			})
+		}, ttlock.FirmwareChanged, front, []string{"hardwareRevision", "firmwareRevision"}},
+		{"settings", func() {
+			srv.UpdateLock(back, func(l *ttlocktest.Lock) {
+				l.Detail.LockAlias = "Back door"
+				l.Detail.AutoLockTime = 30
+			})
+		}, ttlock.SettingsChanged, back, []string{"lockAlias", "autoLockTime"}},
+		{"removed", func() {
+			srv.UpdateLock(back, func(l *ttlocktest.Lock) { l.Owner = "bob" })
+		}, ttlock.LockRemoved, back, nil},
+	}
+	for _, tt := range tests {
+		tt.update()
+		events, err := cache.Refresh()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("%s: events %+v, want one", tt.name, events)
+		}
+		ev := events[0]
+		if ev.Type != tt.want || ev.LockID != tt.lockID || !slices.Equal(ev.Fields, tt.fields) {
+			t.Errorf("%s: got %s on %d with %v, want %s on %d with %v", tt.name, ev.Type, ev.LockID, ev.Fields, tt.want, tt.lockID, tt.fields)
+		}
+		if tt.want == ttlock.LockRemoved {
+			if ev.Old == nil || ev.New != nil {
+				t.Errorf("%s: old %v, new %v", tt.name, ev.Old, ev.New)
+			}
+			if _, ok := cache.Get(back); ok {
+				t.Errorf("%s: lock still cached", tt.name)
+			}
+		} else if ev.Old == nil || ev.New == nil {
+			t.Errorf("%s: old %v, new %v", tt.name, ev.Old, ev.New)
+		}
+	}
+
+	events, err := cache.Refresh()
+	if err != nil || len(events) != 0 {
+		t.Errorf("refresh without changes: %+v, %v", events, err)
+	}
+}
+
+func TestLockCacheRunRefreshesRightAway(t *testing.T) {
+	srv, c := newTestServer(t)
+	srv.AddLock(passcodeLock("Front"))
+	cache, err := ttlock.NewLockCache(c, filepath.Join(t.TempDir(), "locks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	var got []ttlock.LockEvent
+	go func() {
+		defer close(done)
+		cache.Run(ctx, time.Hour, func(events []ttlock.LockEvent, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			got = events
+			cancel()
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not refresh before the first tick")
+	}
+	if len(got) != 1 || got[0].Type != ttlock.LockAdded {
+		t.Errorf("events %+v", got)
+	}
+}
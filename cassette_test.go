@@ -0,0 +1,108 @@
+package ttlock_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/immofon/ttlock"
+)
+
+// recordLockList records a login and a lock list into dir
+func recordLockList(t *testing.T, dir string) {
+	t.Helper()
+	srv, _ := newTestServer(t)
+	lock := passcodeLock("Front")
+	lock.LockData = "secret-lock-data"
+	lock.Detail.NoKeyPwd = "987654"
+	lockID := srv.AddLock(lock)
+
+	cassette, err := ttlock.NewCassette(dir, ttlock.CassetteRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ttlock.Login(srv.ClientID, srv.ClientSecret, "alice", "secret",
+		ttlock.WithBaseURL(srv.URL), ttlock.WithHTTPClient(&http.Client{Transport: cassette}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetLockList(1, 20, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetLockDetail(lockID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCassetteRedactsLockSecrets(t *testing.T) {
+	dir := t.TempDir()
+	recordLockList(t, dir)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("%d recorded files, want 3", len(files))
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"secret-lock-data", "987654"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s contains %q", filepath.Base(file), secret)
+			}
+		}
+	}
+}
+
+func TestCassetteLeavesRequestUnmodified(t *testing.T) {
+	dir := t.TempDir()
+	recordLockList(t, dir)
+	srv, _ := newTestServer(t)
+	for _, mode := range []ttlock.CassetteMode{ttlock.CassetteRecord, ttlock.CassetteReplay} {
+		cassette, err := ttlock.NewCassette(dir, mode, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, getBody := range []bool{true, false} {
+			form := "clientId=" + srv.ClientID + "&clientSecret=" + srv.ClientSecret + "&username=alice&password=secret"
+			req, err := http.NewRequest("POST", srv.URL+"/oauth2/token", strings.NewReader(form))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if !getBody {
+				req.GetBody = nil
+			}
+			body := req.Body
+			resp, err := cassette.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("mode %d: %v", mode, err)
+			}
+			resp.Body.Close()
+			if req.Body != body || (req.GetBody == nil) == getBody {
+				t.Errorf("mode %d, GetBody %v: request modified", mode, getBody)
+			}
+		}
+	}
+}
+
+func TestCassetteNumbersAfterExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	recordLockList(t, dir)
+	// With a gap in the numbering, new files go after the highest number
+	if err := os.Remove(filepath.Join(dir, "0001-post-oauth2_token.json")); err != nil {
+		t.Fatal(err)
+	}
+	recordLockList(t, dir)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 5 {
+		t.Fatalf("%d files, want 5", len(files))
+	}
+	if last := filepath.Base(files[len(files)-1]); last != "0006-get-v3_lock_detail.json" {
+		t.Errorf("last file %s, want 0006-get-v3_lock_detail.json", last)
+	}
+}
@@ -4,12 +4,13 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,15 +25,31 @@ type Client struct {
 	ClientSecret string
 	Username     string
 	Password     string // Plain text password; will be MD5 hashed automatically
+	PasswordMD5  string // Lowercase hex MD5 of the password; used instead of Password when set
 	BaseURL      string
 	HTTPClient   *http.Client
+	Locale       Locale // Language of error messages; defaults to LocaleZhCN
 
-	access_token_resp      AccessTokenResponse
-	access_token_resp_lock sync.RWMutex
+	autoRegion bool
+	clock      Clock
+	skew       atomic.Int64 // estimated server time minus clock time, in nanoseconds
+	tokens     TokenSource
 }
 
-// NewClient creates a new TTLock API client, defaulting to the China base URL
-func NewClient(clientID, clientSecret string, username, password string) *Client {
+// NewClient creates a new TTLock API client, defaulting to the China base URL.
+// Options are applied before the client logs in.
+// It panics if the login fails; use Login to handle the error instead.
+func NewClient(clientID, clientSecret string, username, password string, opts ...Option) *Client {
+	c, err := Login(clientID, clientSecret, username, password, opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return c
+}
+
+// Login creates a new TTLock API client like NewClient, but returns an error
+// instead of panicking when the login fails.
+func Login(clientID, clientSecret string, username, password string, opts ...Option) (*Client, error) {
 	c := &Client{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
@@ -43,76 +60,91 @@ func NewClient(clientID, clientSecret string, username, password string) *Client
 			Timeout: 10 * time.Second,
 		},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
 
-	// Initialize access token
-	tokenResp, err := c.GetAccessToken()
+	// Initialize access token, trying other regions if auto-detection is enabled
+	var tokenResp *AccessTokenResponse
+	var err error
+	for _, baseURL := range c.loginBaseURLs() {
+		c.BaseURL = baseURL
+		tokenResp, err = c.GetAccessToken()
+		if !errors.Is(err, ErrInvalidClient) && !errors.Is(err, ErrInvalidUsernameOrPass) {
+			break
+		}
+	}
 	if err != nil {
-		panic(fmt.Sprintf("failed to get access token: %v", err))
+		return nil, fmt.Errorf("failed to get access token: %w", err)
 	}
-	c.update_access_token_resp(*tokenResp)
 
-	// Start background goroutine to refresh access token
-	go c.refresh_access_token()
+	// Later tokens come from the refresh grant, falling back to the password
+	c.tokens = &reuseTokenSource{token: *tokenResp, fetch: c.passwordGrant}
+
+	return c, nil
+}
 
+// NewClientWithTokenSource creates a client that calls the API with access
+// tokens from ts, e.g. a StaticTokenSource holding a token obtained by a
+// central auth service. No password is needed and the client does not log in;
+// ts is asked for a token on every request.
+func NewClientWithTokenSource(clientID string, ts TokenSource, opts ...Option) *Client {
+	c := &Client{
+		ClientID: clientID,
+		BaseURL:  CNBaseURL,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		tokens: ts,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
 	return c
 }
 
-// update_access_token_resp safely updates the access token response
-func (c *Client) update_access_token_resp(resp AccessTokenResponse) {
-	c.access_token_resp_lock.Lock()
-	defer c.access_token_resp_lock.Unlock()
-	c.access_token_resp = resp
+// Token returns a valid access token from the client's TokenSource,
+// implementing TokenSource.
+func (c *Client) Token() (AccessTokenResponse, error) {
+	if c.tokens == nil {
+		return AccessTokenResponse{}, errors.New("ttlock: client has no token source; use Login or NewClientWithTokenSource")
+	}
+	return c.tokens.Token()
 }
 
-// AccessToken retrieves the current access token
+// AccessToken retrieves the current access token, or "" if none can be obtained.
 func (c *Client) AccessToken() string {
-	c.access_token_resp_lock.RLock()
-	defer c.access_token_resp_lock.RUnlock()
-	return c.access_token_resp.AccessToken
-}
-
-// this function should be runed by goroutine in background by caller.
-// It should contain a dead loop to periodically check and refresh the access token if needed.
-// It refreshes the token depends on .ExpiresIn field by min(.ExpiresIn/2, 1 day)
-func (c *Client) refresh_access_token() {
-	for {
-		// Calculate the sleep duration based on ExpiresIn
-		c.access_token_resp_lock.RLock()
-		expiresIn := c.access_token_resp.ExpiresIn
-		refreshToken := c.access_token_resp.RefreshToken
-		c.access_token_resp_lock.RUnlock()
-
-		// Default to 1 day if ExpiresIn is too large
-		refreshInterval := time.Duration(expiresIn/2) * time.Second
-		if refreshInterval > 24*time.Hour {
-			refreshInterval = 24 * time.Hour
-		}
-
-		// Sleep for the calculated duration
-		time.Sleep(refreshInterval)
-
-		// Refresh the access token with retry logic
-		var err error
-		for i := 0; i < 3; i++ {
-			newTokenResp, retryErr := c.RefreshAccessToken(refreshToken)
-			if retryErr != nil {
-				err = retryErr
-				fmt.Printf("failed to refresh access token (attempt %d): %v\n", i+1, retryErr)
-				time.Sleep(2 * time.Second) // Wait before retrying
-				continue
-			}
-
-			// Update the access token response on success
-			c.update_access_token_resp(*newTokenResp)
-			err = nil
-			break
-		}
+	token, _ := c.accessToken()
+	return token
+}
 
-		// Panic if all retries fail
-		if err != nil {
-			panic(fmt.Sprintf("failed to refresh access token after 3 attempts: %v", err))
-		}
+// accessToken returns the access token to send with a request
+func (c *Client) accessToken() (string, error) {
+	token, err := c.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
 	}
+	return token.AccessToken, nil
+}
+
+// SetLocale sets the language of error messages returned by the client
+func (c *Client) SetLocale(locale Locale) {
+	c.Locale = locale
+}
+
+// errorLocale implements localized
+func (c *Client) errorLocale() Locale {
+	return c.Locale
+}
+
+// newError creates an Error in the client's locale
+func (c *Client) newError(code ErrorCode) *Error {
+	return NewLocalizedError(code, c.Locale)
+}
+
+// responseError creates an Error in the client's locale from an API response
+func (c *Client) responseError(errcode int, errmsg string) *Error {
+	return newResponseError(errcode, errmsg, c.Locale)
 }
 
 // SetBaseURL sets the API base URL
@@ -120,6 +152,76 @@ func (c *Client) SetBaseURL(url string) {
 	c.BaseURL = url
 }
 
+// apiStatus holds the error fields shared by all API responses
+type apiStatus struct {
+	Errcode int    `json:"errcode"`
+	Errmsg  string `json:"errmsg"`
+}
+
+// do sends req and decodes the JSON response into out.
+// It returns *HTTPError for non-2xx statuses, *DecodeError for malformed
+// bodies and *APIError when the response carries a non-zero errcode.
+// A request rejected with ErrInvalidRequestTime is sent once more with its
+// date parameter corrected by the skew learned from the rejection.
+func (c *Client) do(req *http.Request, out interface{}) error {
+	err := c.send(req, out)
+	if errors.Is(err, ErrInvalidRequestTime) {
+		if retry, ok := c.redate(req); ok {
+			return c.send(retry, out)
+		}
+	}
+	return err
+}
+
+// send performs a single round trip for do
+func (c *Client) send(req *http.Request, out interface{}) error {
+	sent := c.localNow()
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return newNetworkError(req, err)
+	}
+	defer resp.Body.Close()
+	c.observeSkew(resp, sent, c.localNow())
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &HTTPError{
+			StatusCode: resp.StatusCode,
+			Method:     req.Method,
+			Path:       req.URL.Path,
+			Body:       truncateBody(body),
+		}
+	}
+
+	var status apiStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return &DecodeError{Raw: body, Err: err}
+	}
+	if status.Errcode != 0 {
+		return c.responseError(status.Errcode, status.Errmsg)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return &DecodeError{Raw: body, Err: err}
+	}
+	return nil
+}
+
+// hashPassword returns the lowercase hex MD5 of a plain text password, as the API expects
+func hashPassword(password string) string {
+	hasher := md5.New()
+	hasher.Write([]byte(password))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
 // AccessTokenResponse represents the response from the oauth2/token endpoint
 type AccessTokenResponse struct {
 	AccessToken  string `json:"access_token"`
@@ -128,17 +230,23 @@ type AccessTokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"` // in seconds
 	Errcode      int    `json:"errcode"`
 	Errmsg       string `json:"errmsg"`
+
+	// Expiry is when the access token expires, computed from ExpiresIn when
+	// the token is obtained. The zero value means it never expires.
+	Expiry time.Time `json:"expiry,omitzero"`
 }
 
 // GetAccessToken obtains an access token using the user's credentials.
 // The password should be the plain text password; it will be MD5 hashed automatically.
+// If PasswordMD5 is set, it is sent as is and Password is ignored.
 func (c *Client) GetAccessToken() (*AccessTokenResponse, error) {
 	endpoint := c.BaseURL + "/oauth2/token"
 
 	// MD5 hash the password
-	hasher := md5.New()
-	hasher.Write([]byte(c.Password))
-	md5Password := hex.EncodeToString(hasher.Sum(nil))
+	md5Password := c.PasswordMD5
+	if md5Password == "" {
+		md5Password = hashPassword(c.Password)
+	}
 
 	data := url.Values{}
 	data.Set("clientId", c.ClientID)
@@ -153,25 +261,11 @@ func (c *Client) GetAccessToken() (*AccessTokenResponse, error) {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
 	var tokenResp AccessTokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if tokenResp.Errcode != 0 {
-		return nil, NewError(ErrorCode(tokenResp.Errcode))
+	if err := c.do(req, &tokenResp); err != nil {
+		return nil, err
 	}
+	tokenResp.setExpiry()
 
 	return &tokenResp, nil
 }
@@ -193,25 +287,11 @@ func (c *Client) RefreshAccessToken(refreshToken string) (*AccessTokenResponse,
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
 	var tokenResp AccessTokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if tokenResp.Errcode != 0 {
-		return nil, NewError(ErrorCode(tokenResp.Errcode))
+	if err := c.do(req, &tokenResp); err != nil {
+		return nil, err
 	}
+	tokenResp.setExpiry()
 
 	return &tokenResp, nil
 }
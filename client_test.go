@@ -0,0 +1,27 @@
+package ttlock_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/immofon/ttlock"
+)
+
+func TestNetworkErrorHidesAccessToken(t *testing.T) {
+	srv, c := newTestServer(t)
+	token := c.AccessToken()
+	srv.Close()
+
+	_, err := c.GetLockList(1, 20, "", 0)
+	var netErr *ttlock.NetworkError
+	if !errors.As(err, &netErr) {
+		t.Fatalf("got %T %v, want a *NetworkError", err, err)
+	}
+	if netErr.Method != "GET" || netErr.Path != "/v3/lock/list" {
+		t.Errorf("error names %s %s", netErr.Method, netErr.Path)
+	}
+	if msg := err.Error(); strings.Contains(msg, token) || strings.Contains(msg, "accessToken") {
+		t.Errorf("error leaks the query: %s", msg)
+	}
+}
@@ -0,0 +1,103 @@
+package ttlock
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clock tells the current time. Replace it with WithClock in tests or on
+// devices with their own time source.
+type Clock interface {
+	Now() time.Time
+}
+
+// skewThreshold is the smallest change of the estimated skew that is applied.
+// The HTTP Date header has a resolution of one second, so smaller differences
+// are noise.
+const skewThreshold = 2 * time.Second
+
+// WithClock sets the clock used for the date parameter of requests.
+func WithClock(clock Clock) Option {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// Now returns the client's estimate of the server time: the clock's time
+// corrected by the skew observed in the Date header of responses.
+func (c *Client) Now() time.Time {
+	return c.localNow().Add(c.Skew())
+}
+
+// localNow returns the clock's time without skew correction
+func (c *Client) localNow() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// Skew returns how far the server's clock is ahead of the client's clock.
+func (c *Client) Skew() time.Duration {
+	return time.Duration(c.skew.Load())
+}
+
+// date returns the value of the date parameter sent with every request
+func (c *Client) date() string {
+	return strconv.FormatInt(c.Now().UnixMilli(), 10)
+}
+
+// observeSkew updates the skew estimate from the Date header of resp.
+// sent and received are the clock's times around the request.
+func (c *Client) observeSkew(resp *http.Response, sent, received time.Time) {
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// The server most likely stamped the response halfway through the round trip
+	skew := serverTime.Sub(sent.Add(received.Sub(sent) / 2))
+	if diff := skew - c.Skew(); diff > skewThreshold || diff < -skewThreshold {
+		c.skew.Store(int64(skew))
+	}
+}
+
+// redate returns a copy of req with the date parameter set to the current
+// corrected time, or false if req has no date parameter.
+func (c *Client) redate(req *http.Request) (*http.Request, bool) {
+	retry := req.Clone(req.Context())
+
+	if query := req.URL.Query(); query.Has("date") {
+		query.Set("date", c.date())
+		retry.URL.RawQuery = query.Encode()
+		return retry, true
+	}
+
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, false
+	}
+	form, err := url.ParseQuery(string(data))
+	if err != nil || !form.Has("date") {
+		return nil, false
+	}
+	form.Set("date", c.date())
+	encoded := form.Encode()
+	retry.Body = io.NopCloser(strings.NewReader(encoded))
+	retry.ContentLength = int64(len(encoded))
+	retry.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(encoded)), nil
+	}
+	return retry, true
+}
@@ -0,0 +1,76 @@
+package ttlock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/immofon/ttlock"
+)
+
+func TestSkewIsLearnedFromDateHeader(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.SetClock(func() time.Time { return time.Now().Add(time.Hour) })
+
+	c, err := ttlock.Login(srv.ClientID, srv.ClientSecret, "alice", "secret", ttlock.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skew := c.Skew(); skew < time.Hour-5*time.Second || skew > time.Hour+5*time.Second {
+		t.Fatalf("skew %v after login, want about 1h", skew)
+	}
+
+	if _, err := c.GetLockList(1, 20, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests("/v3/lock/list"); n != 1 {
+		t.Errorf("%d list requests, want 1", n)
+	}
+}
+
+func TestRequestIsRedatedAfterClockDrift(t *testing.T) {
+	srv, c := newTestServer(t)
+
+	// The server's clock jumps after login; the first request is rejected
+	// with ErrInvalidRequestTime and retried with the corrected date.
+	srv.SetClock(func() time.Time { return time.Now().Add(-2 * time.Hour) })
+	if _, err := c.GetLockList(1, 20, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests("/v3/lock/list"); n != 2 {
+		t.Errorf("%d list requests, want 2", n)
+	}
+	if skew := c.Skew(); skew > -2*time.Hour+5*time.Second || skew < -2*time.Hour-5*time.Second {
+		t.Errorf("skew %v, want about -2h", skew)
+	}
+
+	// POST bodies are redated too
+	srv.SetClock(func() time.Time { return time.Now().Add(time.Hour) })
+	if err := c.DeleteKey(1); !ttlock.IsNotFound(err) {
+		t.Fatalf("got %v, want ErrKeyNotExist after redating", err)
+	}
+	if n := srv.Requests("/v3/key/delete"); n != 2 {
+		t.Errorf("%d delete requests, want 2", n)
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func TestWithClockSetsDate(t *testing.T) {
+	srv, _ := newTestServer(t)
+	past := time.Now().Add(-30 * 24 * time.Hour)
+	srv.SetClock(func() time.Time { return past })
+
+	c, err := ttlock.Login(srv.ClientID, srv.ClientSecret, "alice", "secret",
+		ttlock.WithBaseURL(srv.URL), ttlock.WithClock(fixedClock(past)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetLockList(1, 20, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests("/v3/lock/list"); n != 1 {
+		t.Errorf("%d list requests, want 1", n)
+	}
+}
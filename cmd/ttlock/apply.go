@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/immofon/ttlock"
+	"github.com/urfave/cli/v2"
+)
+
+var applyCmd = &cli.Command{
+	Name:  "apply",
+	Usage: "Make eKeys and passcodes match a policy file",
+	Description: "Compares the policy with the locks, prints the plan and, after confirmation, applies it.\n" +
+		"Keys and passcodes are matched by name. Times are wall-clock times at the lock.\n" +
+		"The plan is printed as text unless -output or -template is given.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "file",
+			Aliases:  []string{"f"},
+			Required: true,
+			Usage:    "Policy file (TOML, or JSON with a .json extension)",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only print the plan",
+		},
+		&cli.BoolFlag{
+			Name:    "yes",
+			Aliases: []string{"auto-approve"},
+			Usage:   "Apply without asking for confirmation",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		policy, err := loadPolicy(c.String("file"))
+		if err != nil {
+			return err
+		}
+
+		plan, err := client.PlanPolicy(c.Context, policy)
+		if err != nil {
+			return err
+		}
+		if err := printText(c, plan); err != nil {
+			return err
+		}
+		if plan.Empty() || c.Bool("dry-run") {
+			return nil
+		}
+
+		if !c.Bool("yes") {
+			fmt.Fprint(os.Stderr, "\nApply these changes? Only 'yes' is accepted: ")
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if strings.TrimSpace(answer) != "yes" {
+				return cli.Exit("Apply cancelled.", 1)
+			}
+		}
+
+		n, err := client.ApplyPlan(plan)
+		fmt.Fprintf(os.Stderr, "\nApplied %d of %d changes.\n", n, len(plan.Changes))
+		return err
+	},
+}
+
+// loadPolicy reads a policy file
+func loadPolicy(path string) (*ttlock.Policy, error) {
+	var policy ttlock.Policy
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &policy); err != nil {
+			return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+		}
+	} else if _, err := toml.DecodeFile(path, &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	return &policy, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/immofon/ttlock"
+	"github.com/urfave/cli/v2"
+)
+
+var accessoryTypeNames = map[int]string{
+	1: "keypad",
+	2: "remote",
+	3: "door-sensor",
+}
+
+var batteryCmd = &cli.Command{
+	Name:  "battery",
+	Usage: "Report locks with low battery (exits with status 1 if any are found)",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "below",
+			Usage: "Battery threshold in percent",
+			Value: 20,
+		},
+		&cli.BoolFlag{
+			Name:  "accessories",
+			Usage: "Also check accessory batteries",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Output format (json, jsonl, csv, table); overrides --output",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		threshold := c.Int("below")
+
+		report, err := client.BatteryReport(context.Background(), threshold, c.Bool("accessories"))
+		if err != nil {
+			return err
+		}
+
+		// Table and CSV output list one row per device
+		if format := outputFormat(c, "json"); (format == "table" || format == "csv") && c.String("template") == "" {
+			err = printList(c, batteryRows(report), batteryColumns, nil, format)
+		} else {
+			err = printValue(c, report)
+		}
+		if err != nil {
+			return err
+		}
+
+		if report.Low > 0 {
+			return cli.Exit(fmt.Sprintf("%d of %d locks below %d%% battery", report.Low, report.Scanned, threshold), 1)
+		}
+		return nil
+	},
+}
+
+var batteryColumns = []string{"group", "lockId", "lockAlias", "device", "mac", "battery"}
+
+// batteryRow is one lock or accessory of a battery report
+type batteryRow struct {
+	Group     string `json:"group"`
+	LockID    int    `json:"lockId"`
+	LockAlias string `json:"lockAlias"`
+	Device    string `json:"device"`
+	Mac       string `json:"mac"`
+	Battery   int    `json:"battery"`
+}
+
+// batteryRows flattens a report into one row per lock and accessory
+func batteryRows(report *ttlock.BatteryReportResult) []batteryRow {
+	var rows []batteryRow
+	for _, g := range report.Groups {
+		for _, l := range g.Locks {
+			rows = append(rows, batteryRow{g.GroupName, l.Lock.LockID, l.Lock.LockAlias, "lock", l.Lock.LockMac, l.Lock.ElectricQuantity})
+			for _, a := range l.Accessories {
+				device, ok := accessoryTypeNames[a.AccessoryType]
+				if !ok {
+					device = "accessory-" + strconv.Itoa(a.AccessoryType)
+				}
+				rows = append(rows, batteryRow{g.GroupName, l.Lock.LockID, l.Lock.LockAlias, device, a.AccessoryMac, a.ElectricQuantity})
+			}
+		}
+	}
+	return rows
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/immofon/ttlock"
+	"github.com/immofon/ttlock/ttlocktest"
+	"github.com/urfave/cli/v2"
+)
+
+// runApp runs the CLI with args against srv, logged in as alice, and returns
+// what it printed and its exit code
+func runApp(t *testing.T, srv *ttlocktest.Server, args ...string) (string, int) {
+	t.Helper()
+	for _, name := range []string{"TTLOCK_CLIENT_ID", "TTLOCK_CLIENT_SECRET", "TTLOCK_CLIENT_SECRET_FILE", "TTLOCK_USERNAME", "TTLOCK_PASSWORD", "TTLOCK_PASSWORD_FILE", "TTLOCK_PASSWORD_MD5", "TTLOCK_PASSWORD_MD5_FILE", "TTLOCK_CONFIG", "TTLOCK_PROFILE"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	config := filepath.Join(t.TempDir(), "config.toml")
+	toml := fmt.Sprintf("client_id = %q\nclient_secret = %q\nusername = \"alice\"\npassword = \"secret\"\nbase_url = %q\n", srv.ClientID, srv.ClientSecret, srv.URL)
+	if err := os.WriteFile(config, []byte(toml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, exiter, errWriter := os.Stdout, cli.OsExiter, cli.ErrWriter
+	code := 0
+	os.Stdout, cli.ErrWriter = w, io.Discard
+	cli.OsExiter = func(c int) { code = c }
+	defer func() {
+		os.Stdout, cli.OsExiter, cli.ErrWriter = stdout, exiter, errWriter
+		client = nil
+	}()
+
+	out := make(chan string)
+	go func() {
+		var b bytes.Buffer
+		io.Copy(&b, r)
+		out <- b.String()
+	}()
+	err = newApp().Run(append([]string{"ttlock", "--config", config}, args...))
+	w.Close()
+	printed := <-out
+	if err != nil && code == 0 {
+		t.Fatalf("ttlock %s: %v", strings.Join(args, " "), err)
+	}
+	return printed, code
+}
+
+func TestBatteryFormatCSV(t *testing.T) {
+	srv := ttlocktest.NewServer()
+	defer srv.Close()
+	srv.AddUser("alice", "secret")
+	low := srv.AddLock(ttlocktest.Lock{Owner: "alice", Detail: ttlock.LockDetail{LockAlias: "Front", LockMac: "AA:BB", ElectricQuantity: 12}})
+	srv.AddLock(ttlocktest.Lock{Owner: "alice", Detail: ttlock.LockDetail{LockAlias: "Back", ElectricQuantity: 80}})
+
+	out, code := runApp(t, srv, "battery", "--below", "20", "--format", "csv")
+	want := fmt.Sprintf("group,lockId,lockAlias,device,mac,battery\n,%d,Front,lock,AA:BB,12\n", low)
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+	if code != 1 {
+		t.Errorf("exit code %d, want 1 for a low battery", code)
+	}
+}
+
+func TestBatteryFormatOverridesOutput(t *testing.T) {
+	srv := ttlocktest.NewServer()
+	defer srv.Close()
+	srv.AddUser("alice", "secret")
+	srv.AddLock(ttlocktest.Lock{Owner: "alice", Detail: ttlock.LockDetail{LockAlias: "Back", ElectricQuantity: 80}})
+
+	out, code := runApp(t, srv, "--output", "table", "battery", "--format", "json")
+	var report ttlock.BatteryReportResult
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if report.Scanned != 1 || report.Low != 0 || code != 0 {
+		t.Errorf("report %+v, exit code %d", report, code)
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/immofon/ttlock"
@@ -44,7 +47,7 @@ var lockCmd = &cli.Command{
 		if err != nil {
 			return err
 		}
-		return printJSON(detail)
+		return printValue(c, detail)
 	},
 }
 
@@ -70,6 +73,10 @@ var listLockCmd = &cli.Command{
 			Name:  "g",
 			Usage: "Group ID",
 		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "Fetch all pages",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		pageNo := c.Int("n")
@@ -77,11 +84,22 @@ var listLockCmd = &cli.Command{
 		lockAlias := c.String("a")
 		groupID := c.Int("g")
 
+		if c.Bool("all") {
+			locks, err := ttlock.Collect(client.Locks(context.Background(), ttlock.LockFilter{
+				LockAlias: lockAlias,
+				GroupID:   groupID,
+			}))
+			if err != nil {
+				return err
+			}
+			return printList(c, locks, lockColumns, nil, "json")
+		}
+
 		list, err := client.GetLockList(pageNo, pageSize, lockAlias, groupID)
 		if err != nil {
 			return err
 		}
-		return printJSON(list)
+		return printList(c, list.List, lockColumns, list, "json")
 	},
 }
 
@@ -114,6 +132,10 @@ var listPasscodeCmd = &cli.Command{
 			Aliases: []string{"q"},
 			Usage:   "Search string",
 		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "Fetch all pages",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		lockID := c.Int("id")
@@ -122,11 +144,73 @@ var listPasscodeCmd = &cli.Command{
 		orderBy := c.Int("o")
 		searchStr := c.String("search")
 
+		if c.Bool("all") {
+			passcodes, err := ttlock.Collect(client.Passcodes(context.Background(), lockID, ttlock.PasscodeFilter{
+				OrderBy:   orderBy,
+				SearchStr: searchStr,
+			}))
+			if err != nil {
+				return err
+			}
+			return printList(c, passcodes, passcodeColumns, nil, "json")
+		}
+
 		list, err := client.GetPasscodeList(lockID, pageNo, pageSize, orderBy, searchStr)
 		if err != nil {
 			return err
 		}
-		return printJSON(list)
+		return printList(c, list.List, passcodeColumns, list, "json")
+	},
+}
+
+var listKeyCmd = &cli.Command{
+	Name:  "list-key",
+	Usage: "List eKeys",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:     "id",
+			Required: true,
+			Usage:    "Lock ID",
+		},
+		&cli.IntFlag{
+			Name:  "n",
+			Usage: "Page number",
+			Value: 1,
+		},
+		&cli.IntFlag{
+			Name:  "s",
+			Usage: "Page size",
+			Value: 20,
+		},
+		&cli.StringFlag{
+			Name:    "search",
+			Aliases: []string{"q"},
+			Usage:   "Search string",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "Fetch all pages",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		lockID := c.Int("id")
+		searchStr := c.String("search")
+
+		if c.Bool("all") {
+			keys, err := ttlock.Collect(client.Keys(context.Background(), lockID, ttlock.KeyFilter{
+				SearchStr: searchStr,
+			}))
+			if err != nil {
+				return err
+			}
+			return printList(c, keys, keyColumns, nil, "json")
+		}
+
+		list, err := client.GetKeyList(lockID, c.Int("n"), c.Int("s"), searchStr)
+		if err != nil {
+			return err
+		}
+		return printList(c, list.List, keyColumns, list, "json")
 	},
 }
 
@@ -140,9 +224,12 @@ var genPassCmd = &cli.Command{
 			Usage:    "Lock ID",
 		},
 		&cli.IntFlag{
-			Name:     "t",
-			Required: true,
-			Usage:    "Passcode type",
+			Name:  "t",
+			Usage: "Passcode type",
+		},
+		&cli.StringFlag{
+			Name:  "cyclic",
+			Usage: "Cyclic schedule, e.g. \"Mon-Fri 08-18\" (replaces -t; -s/-e give the validity dates)",
 		},
 		&cli.StringFlag{
 			Name:  "n",
@@ -151,41 +238,68 @@ var genPassCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:     "s",
 			Required: true,
-			Usage:    "Start date (YYYYMMDD-HH)",
+			Usage:    "Start date (YYYYMMDD-HH; with --cyclic, the date at the lock)",
 		},
 		&cli.StringFlag{
 			Name:     "e",
 			Required: true,
-			Usage:    "End date (YYYYMMDD-HH)",
+			Usage:    "End date (YYYYMMDD-HH; with --cyclic, the date at the lock)",
 		},
 	},
 	Action: func(c *cli.Context) error {
 		lockID := c.Int("id")
 		pwdType := ttlock.PasscodeType(c.Int("t"))
 		pwdName := c.String("n")
-		startDateStr := c.String("s")
-		endDateStr := c.String("e")
 
-		startDate, err := parseDate(startDateStr)
+		if cyclic := c.String("cyclic"); cyclic != "" {
+			schedule, err := ttlock.ParseCyclicSchedule(cyclic)
+			if err != nil {
+				return err
+			}
+			// The validity dates of a cyclic passcode are dates at the lock
+			detail, err := client.GetLockDetail(lockID)
+			if err != nil {
+				return err
+			}
+			start, err := parseLockTime(c.String("s"), detail.Location())
+			if err != nil {
+				return fmt.Errorf("invalid start date: %w", err)
+			}
+			end, err := parseLockTime(c.String("e"), detail.Location())
+			if err != nil {
+				return fmt.Errorf("invalid end date: %w", err)
+			}
+			resp, err := client.GetCyclicPasscode(lockID, pwdName, schedule, start, end)
+			if err != nil {
+				return err
+			}
+			return printValue(c, resp)
+		}
+		if pwdType == 0 {
+			return fmt.Errorf("either -t or --cyclic is required")
+		}
+
+		startDate, err := parseDate(c.String("s"))
 		if err != nil {
 			return fmt.Errorf("invalid start date: %w", err)
 		}
-		endDate, err := parseDate(endDateStr)
+		endDate, err := parseDate(c.String("e"))
 		if err != nil {
 			return fmt.Errorf("invalid end date: %w", err)
 		}
-
 		resp, err := client.GetRandomPasscode(lockID, pwdType, pwdName, startDate, endDate)
 		if err != nil {
 			return err
 		}
-		return printJSON(resp)
+		return printValue(c, resp)
 	},
 }
 
 var sendKeyCmd = &cli.Command{
 	Name:  "sendkey",
 	Usage: "Send eKey",
+	Description: "The key is valid from --start to --end, or for the --for duration after --start.\n" +
+		"Times are wall-clock times at the lock. Use --permanent for a key without end.",
 	Flags: []cli.Flag{
 		&cli.IntFlag{
 			Name:     "id",
@@ -198,41 +312,280 @@ var sendKeyCmd = &cli.Command{
 			Usage:    "Receiver username",
 		},
 		&cli.StringFlag{
-			Name:  "n",
-			Usage: "Key name",
+			Name:     "name",
+			Aliases:  []string{"n"},
+			Required: true,
+			Usage:    "Key name (at most 50 characters)",
 		},
 		&cli.StringFlag{
-			Name:     "s",
-			Required: true,
-			Usage:    "Start date (YYYYMMDD-HH)",
+			Name:    "start",
+			Aliases: []string{"s"},
+			Usage:   "Start time at the lock (now, YYYYMMDD-HH, YYYYMMDD-HHMM or RFC 3339) (default: now)",
 		},
 		&cli.StringFlag{
-			Name:     "e",
-			Required: true,
-			Usage:    "End date (YYYYMMDD-HH)",
+			Name:    "end",
+			Aliases: []string{"e"},
+			Usage:   "End time at the lock (YYYYMMDD-HH, YYYYMMDD-HHMM or RFC 3339)",
+		},
+		&cli.StringFlag{
+			Name:  "for",
+			Usage: "Validity after the start instead of --end, e.g. 3d, 2w or 12h",
+		},
+		&cli.BoolFlag{
+			Name:  "permanent",
+			Usage: "Send a key without start and end",
+		},
+		&cli.StringFlag{
+			Name:  "cyclic",
+			Usage: "Only valid in weekly periods within the start and end, e.g. \"Mon-Fri 08-18\"",
+		},
+		&cli.StringFlag{
+			Name:  "remarks",
+			Usage: "Message to the receiver",
+		},
+		&cli.BoolFlag{
+			Name:  "remote-unlock",
+			Usage: "Allow remote unlocking (--remote-unlock=false to forbid it)",
+		},
+		&cli.BoolFlag{
+			Name:  "admin",
+			Usage: "Make the receiver an authorized admin of the lock",
+		},
+		&cli.BoolFlag{
+			Name:  "create-user",
+			Usage: "Create a TTLock account for a receiver email or phone number without one",
 		},
 	},
 	Action: func(c *cli.Context) error {
 		lockID := c.Int("id")
-		receiverUsername := c.String("to")
-		keyName := c.String("n")
-		startDateStr := c.String("s")
-		endDateStr := c.String("e")
 
-		startDate, err := parseDate(startDateStr)
-		if err != nil {
-			return fmt.Errorf("invalid start date: %w", err)
+		options := &ttlock.SendKeyOptions{Remarks: c.String("remarks")}
+		if c.IsSet("remote-unlock") {
+			options.RemoteEnable = 2
+			if c.Bool("remote-unlock") {
+				options.RemoteEnable = 1
+			}
+		}
+		if c.Bool("admin") {
+			options.KeyRight = 1
+		}
+		if c.Bool("create-user") {
+			options.CreateUser = 1
+		}
+
+		var startDate, endDate int64
+		if c.Bool("permanent") {
+			for _, name := range []string{"start", "end", "for", "cyclic"} {
+				if c.IsSet(name) {
+					return fmt.Errorf("--permanent cannot be combined with --%s", name)
+				}
+			}
+		} else {
+			if c.IsSet("end") == c.IsSet("for") {
+				return fmt.Errorf("either --end, --for or --permanent is required")
+			}
+
+			detail, err := client.GetLockDetail(lockID)
+			if err != nil {
+				return err
+			}
+			loc := detail.Location()
+
+			start, err := parseLockTime(c.String("start"), loc)
+			if err != nil {
+				return fmt.Errorf("invalid start: %w", err)
+			}
+			var end time.Time
+			if c.IsSet("for") {
+				d, err := parseSpan(c.String("for"))
+				if err != nil {
+					return err
+				}
+				end = start.Add(d)
+			} else if end, err = parseLockTime(c.String("end"), loc); err != nil {
+				return fmt.Errorf("invalid end: %w", err)
+			}
+			if !end.After(start) {
+				return fmt.Errorf("end %s is not after start %s", end.Format(time.RFC3339), start.Format(time.RFC3339))
+			}
+			startDate, endDate = start.UnixMilli(), end.UnixMilli()
+
+			if cyclic := c.String("cyclic"); cyclic != "" {
+				schedule, err := ttlock.ParseCyclicSchedule(cyclic)
+				if err != nil {
+					return err
+				}
+				if options.Cyclic, err = schedule.KeyPeriods(); err != nil {
+					return err
+				}
+			}
 		}
-		endDate, err := parseDate(endDateStr)
+
+		resp, err := client.SendKey(lockID, c.String("to"), c.String("name"), startDate, endDate, options)
 		if err != nil {
-			return fmt.Errorf("invalid end date: %w", err)
+			return err
+		}
+		return printValue(c, resp)
+	},
+}
+
+// parseSpan parses a duration such as 3d, 2w, 12h or 1h30m
+func parseSpan(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(s, "w") || strings.HasSuffix(s, "d"):
+		var n int
+		n, err = strconv.Atoi(s[:len(s)-1])
+		d = time.Duration(n) * 24 * time.Hour
+		if strings.HasSuffix(s, "w") {
+			d *= 7
+		}
+	default:
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q: want e.g. 3d, 2w, 12h or 1h30m", s)
+	}
+	return d, nil
+}
+
+// parseLockTime parses a wall-clock time at the lock: "now", YYYYMMDD-HH,
+// YYYYMMDD-HHMM or RFC 3339 (whose own offset is honored).
+func parseLockTime(s string, loc *time.Location) (time.Time, error) {
+	if s == "" || s == "now" {
+		return client.Now().In(loc), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), nil
+	}
+	for _, layout := range []string{"20060102-15", "20060102-1504"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
 		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want now, YYYYMMDD-HH, YYYYMMDD-HHMM or RFC 3339", s)
+}
+
+// passcodeExplanation is the output of "passcode explain"
+type passcodeExplanation struct {
+	Passcode *ttlock.Passcode `json:"passcode"`
+	At       string           `json:"at"`
+	Valid    bool             `json:"valid"`
+	Reason   string           `json:"reason"`
+}
+
+var passcodeCmd = &cli.Command{
+	Name:  "passcode",
+	Usage: "Passcode tools",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "explain",
+			Usage: "Explain whether a passcode is valid at a given time",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:     "id",
+					Required: true,
+					Usage:    "Lock ID",
+				},
+				&cli.IntFlag{
+					Name:     "pwd-id",
+					Required: true,
+					Usage:    "Passcode ID (keyboardPwdId)",
+				},
+				&cli.StringFlag{
+					Name:  "at",
+					Usage: "Time at the lock (now, YYYYMMDD-HH, YYYYMMDD-HHMM or RFC 3339)",
+					Value: "now",
+				},
+				&cli.StringFlag{
+					Name:  "first-use",
+					Usage: "Time the passcode was first used at the lock, if ever",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				lockID := c.Int("id")
+
+				detail, err := client.GetLockDetail(lockID)
+				if err != nil {
+					return err
+				}
+				loc := detail.Location()
+
+				at, err := parseLockTime(c.String("at"), loc)
+				if err != nil {
+					return err
+				}
+				var firstUse *time.Time
+				if s := c.String("first-use"); s != "" {
+					t, err := parseLockTime(s, loc)
+					if err != nil {
+						return err
+					}
+					firstUse = &t
+				}
+
+				passcode, err := client.FindPasscode(lockID, c.Int("pwd-id"))
+				if err != nil {
+					return err
+				}
 
-		// Using nil for options for now as not specified in CLI flags
-		resp, err := client.SendKey(lockID, receiverUsername, keyName, startDate, endDate, nil)
+				valid, reason := passcode.ValidAt(at, firstUse)
+				return printValue(c, passcodeExplanation{
+					Passcode: passcode,
+					At:       at.Format(time.RFC3339),
+					Valid:    valid,
+					Reason:   reason,
+				})
+			},
+		},
+	},
+}
+
+var featuresCmd = &cli.Command{
+	Name:  "features",
+	Usage: "Print the capability table of a lock",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:     "id",
+			Required: true,
+			Usage:    "Lock ID",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		detail, err := client.GetLockDetail(c.Int("id"))
+		if err != nil {
+			return err
+		}
+		set, err := detail.Features()
 		if err != nil {
 			return err
 		}
-		return printJSON(resp)
+
+		features := ttlock.KnownFeatures()
+		for _, f := range set.Features() {
+			if !f.Known() {
+				features = append(features, f)
+			}
+		}
+
+		rows := make([]featureRow, 0, len(features))
+		for _, f := range features {
+			rows = append(rows, featureRow{
+				Bit:         int(f),
+				Name:        f.Name(),
+				Supported:   set.Has(f),
+				Description: f.Description(client.Locale),
+			})
+		}
+		return printList(c, rows, []string{"bit", "name", "supported", "description"}, nil, "table")
 	},
 }
+
+// featureRow is one line of the "features" capability table
+type featureRow struct {
+	Bit         int    `json:"bit"`
+	Name        string `json:"name"`
+	Supported   bool   `json:"supported"`
+	Description string `json:"description"`
+}
@@ -1,8 +1,201 @@
 package main
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/immofon/ttlock"
+)
+
+// Config is the CLI config file. The top-level account is the default
+// profile; further accounts are listed under [profiles.<name>].
 type Config struct {
-	ClientID     string `toml:"client_id"`
-	ClientSecret string `toml:"client_secret"`
-	Username     string `toml:"username"`
-	Password     string `toml:"password"`
+	Profile
+	Profiles map[string]Profile `toml:"profiles"`
+}
+
+// profile returns the named profile, or the default one for an empty name
+func (c *Config) profile(name string) (*Profile, error) {
+	if name == "" {
+		return &c.Profile, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found in config", name)
+	}
+	return &p, nil
+}
+
+// Profile holds the credentials and server of one TTLock account.
+// Secrets can be given inline, through *_file keys naming a file that holds
+// the secret, or through TTLOCK_* environment variables.
+type Profile struct {
+	ClientID         string `toml:"client_id"`
+	ClientSecret     string `toml:"client_secret"`
+	ClientSecretFile string `toml:"client_secret_file"`
+	Username         string `toml:"username"`
+	Password         string `toml:"password"`
+	PasswordFile     string `toml:"password_file"`
+	PasswordMD5      string `toml:"password_md5"` // lowercase hex MD5 of the password
+	PasswordMD5File  string `toml:"password_md5_file"`
+	Region           string `toml:"region"`   // cn, eu or auto; defaults to cn
+	BaseURL          string `toml:"base_url"` // overrides region
+}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/ttlock/config.toml, falling back
+// to ~/.config when XDG_CONFIG_HOME is unset
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "ttlock.toml"
+	}
+	return filepath.Join(dir, "ttlock", "config.toml")
+}
+
+// checkPrivate refuses files that other users can read
+func checkPrivate(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0o004 != 0 {
+		return fmt.Errorf("%s is world-readable (mode %v); run chmod 600 %s", path, info.Mode().Perm(), path)
+	}
+	return nil
+}
+
+// loadConfig reads the config file. A missing file yields an empty config so
+// that credentials can come from the environment alone.
+func loadConfig(path string) (*Config, error) {
+	var config Config
+	if err := checkPrivate(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &config, nil
+		}
+		return nil, err
+	}
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// readSecretFile returns the trimmed content of a private secret file
+func readSecretFile(path string) (string, error) {
+	if err := checkPrivate(path); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// resolve reads the *_file secrets of the config file, then applies the
+// TTLOCK_* environment overrides, so that the environment always wins
+func (p *Profile) resolve() error {
+	files := []struct {
+		path  string
+		field *string
+	}{
+		{p.ClientSecretFile, &p.ClientSecret},
+		{p.PasswordFile, &p.Password},
+		{p.PasswordMD5File, &p.PasswordMD5},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		secret, err := readSecretFile(f.path)
+		if err != nil {
+			return err
+		}
+		*f.field = secret
+	}
+
+	env := []struct {
+		name  string
+		field *string
+	}{
+		{"TTLOCK_CLIENT_ID", &p.ClientID},
+		{"TTLOCK_USERNAME", &p.Username},
+		{"TTLOCK_REGION", &p.Region},
+		{"TTLOCK_BASE_URL", &p.BaseURL},
+	}
+	for _, e := range env {
+		if v, ok := os.LookupEnv(e.name); ok {
+			*e.field = v
+		}
+	}
+
+	secret, ok, err := envSecret("TTLOCK_CLIENT_SECRET")
+	if err != nil {
+		return err
+	}
+	if ok {
+		p.ClientSecret = secret
+	}
+
+	// A password from the environment replaces both forms of the config file's
+	password, hasPassword, err := envSecret("TTLOCK_PASSWORD")
+	if err != nil {
+		return err
+	}
+	passwordMD5, hasMD5, err := envSecret("TTLOCK_PASSWORD_MD5")
+	if err != nil {
+		return err
+	}
+	if hasPassword || hasMD5 {
+		p.Password, p.PasswordMD5 = password, passwordMD5
+	}
+	return nil
+}
+
+// envSecret returns the secret in the environment variable name, or in the
+// file named by name_FILE. ok is false if neither is set.
+func envSecret(name string) (secret string, ok bool, err error) {
+	if v, ok := os.LookupEnv(name); ok {
+		return v, true, nil
+	}
+	if path, ok := os.LookupEnv(name + "_FILE"); ok {
+		secret, err := readSecretFile(path)
+		return secret, true, err
+	}
+	return "", false, nil
+}
+
+// complete checks that the profile holds all credentials needed to log in
+func (p *Profile) complete() error {
+	if p.ClientID == "" || p.ClientSecret == "" || p.Username == "" || (p.Password == "" && p.PasswordMD5 == "") {
+		return fmt.Errorf("incomplete credentials: client_id, client_secret, username and password (or password_md5) are required; run \"ttlock config init\"")
+	}
+	return nil
+}
+
+// clientOptions returns the ttlock client options selected by the config
+func (c *Profile) clientOptions() ([]ttlock.Option, error) {
+	var opts []ttlock.Option
+	switch c.Region {
+	case "":
+	case "auto":
+		opts = append(opts, ttlock.WithAutoRegion())
+	default:
+		region, err := ttlock.ParseRegion(c.Region)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, ttlock.WithRegion(region))
+	}
+	if c.BaseURL != "" {
+		opts = append(opts, ttlock.WithBaseURL(c.BaseURL))
+	}
+	if c.PasswordMD5 != "" {
+		opts = append(opts, ttlock.WithPasswordMD5(c.PasswordMD5))
+	}
+	return opts, nil
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSecret(t *testing.T, name, secret string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolveEnvironmentWins(t *testing.T) {
+	for _, name := range []string{"TTLOCK_CLIENT_SECRET", "TTLOCK_CLIENT_SECRET_FILE", "TTLOCK_PASSWORD", "TTLOCK_PASSWORD_FILE", "TTLOCK_PASSWORD_MD5", "TTLOCK_PASSWORD_MD5_FILE", "TTLOCK_USERNAME"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	config := func() Profile {
+		return Profile{
+			ClientSecretFile: writeSecret(t, "secret", "file-secret"),
+			Username:         "config-user",
+			PasswordFile:     writeSecret(t, "password", "file-password"),
+			PasswordMD5:      "0123456789abcdef0123456789abcdef",
+		}
+	}
+
+	p := config()
+	if err := p.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if p.ClientSecret != "file-secret" || p.Password != "file-password" {
+		t.Errorf("config file secrets: %+v", p)
+	}
+
+	t.Setenv("TTLOCK_CLIENT_SECRET", "env-secret")
+	t.Setenv("TTLOCK_PASSWORD", "env-password")
+	t.Setenv("TTLOCK_USERNAME", "env-user")
+	p = config()
+	if err := p.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if p.ClientSecret != "env-secret" || p.Password != "env-password" || p.Username != "env-user" {
+		t.Errorf("environment did not win: %+v", p)
+	}
+	if p.PasswordMD5 != "" {
+		t.Errorf("password_md5 %q from the config file kept next to TTLOCK_PASSWORD", p.PasswordMD5)
+	}
+
+	os.Unsetenv("TTLOCK_PASSWORD")
+	t.Setenv("TTLOCK_PASSWORD_MD5_FILE", writeSecret(t, "md5", "fedcba9876543210fedcba9876543210"))
+	p = config()
+	if err := p.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if p.PasswordMD5 != "fedcba9876543210fedcba9876543210" || p.Password != "" {
+		t.Errorf("TTLOCK_PASSWORD_MD5_FILE: %+v", p)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+)
+
+const configTemplate = `# TTLock CLI config. Keep this file private (mode 0600).
+#
+# Secrets may be given inline, or read from private files with the *_file
+# keys. Any value can be overridden by the matching TTLOCK_* environment
+# variable, e.g. TTLOCK_CLIENT_SECRET or TTLOCK_PASSWORD_MD5_FILE.
+
+client_id = ""
+client_secret = ""
+# client_secret_file = "/run/secrets/ttlock_client_secret"
+username = ""
+
+# Prefer the MD5 hash so the plain text password never touches disk:
+#   printf %s 'your password' | md5sum
+password_md5 = ""
+# password_md5_file = "/run/secrets/ttlock_password_md5"
+
+# cn, eu or auto
+region = "cn"
+
+# [profiles.other-account]
+# client_id = ""
+# client_secret = ""
+# username = ""
+# password_md5 = ""
+# region = "eu"
+`
+
+var configCmd = &cli.Command{
+	Name:  "config",
+	Usage: "Manage the config file",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "init",
+			Usage: "Create a config file template",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "force",
+					Usage: "Overwrite an existing config file",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				path := c.String("config")
+
+				if _, err := os.Stat(path); err == nil && !c.Bool("force") {
+					return fmt.Errorf("%s already exists; use --force to overwrite", path)
+				} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					return err
+				}
+				if err := os.WriteFile(path, []byte(configTemplate), 0600); err != nil {
+					return err
+				}
+				// WriteFile keeps the mode of an existing file
+				if err := os.Chmod(path, 0600); err != nil {
+					return err
+				}
+				fmt.Println("wrote", path)
+				return nil
+			},
+		},
+	},
+}
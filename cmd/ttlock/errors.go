@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/immofon/ttlock"
+)
+
+// describeError formats err according to where the failure happened.
+func describeError(err error) string {
+	var apiErr *ttlock.APIError
+	var httpErr *ttlock.HTTPError
+	var decodeErr *ttlock.DecodeError
+	var netErr *ttlock.NetworkError
+
+	switch {
+	case errors.As(err, &apiErr):
+		msg := fmt.Sprintf("API error %d (%s): %s", apiErr.Code, apiErr.ID, apiErr.Message)
+		if apiErr.Errmsg != "" && apiErr.Errmsg != apiErr.Message {
+			msg += fmt.Sprintf(" [server: %s]", apiErr.Errmsg)
+		}
+		return msg
+	case errors.As(err, &httpErr):
+		return fmt.Sprintf("HTTP error %d on %s %s: %s", httpErr.StatusCode, httpErr.Method, httpErr.Path, httpErr.Body)
+	case errors.As(err, &decodeErr):
+		return fmt.Sprintf("invalid response: %v", decodeErr)
+	case errors.As(err, &netErr):
+		// The method and path are already known, so skip the URL repeated by url.Error
+		cause := netErr.Err
+		var urlErr *url.Error
+		if errors.As(cause, &urlErr) {
+			cause = urlErr.Err
+		}
+		return fmt.Sprintf("network error on %s %s: %v", netErr.Method, netErr.Path, cause)
+	}
+	return err.Error()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/immofon/ttlock"
+	"github.com/immofon/ttlock/ttlocktest"
+)
+
+func TestDescribeNetworkError(t *testing.T) {
+	err := fmt.Errorf("list locks: %w", &ttlock.NetworkError{
+		Method: "POST",
+		Path:   "/v3/lock/list",
+		Err:    &url.Error{Op: "Post", URL: "https://euapi.ttlock.com/v3/lock/list", Err: errors.New("connection refused")},
+	})
+	if got, want := describeError(err), "network error on POST /v3/lock/list: connection refused"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	srv := ttlocktest.NewServer()
+	srv.AddUser("alice", "secret")
+	srv.Close()
+	_, err = ttlock.Login(srv.ClientID, srv.ClientSecret, "alice", "secret", ttlock.WithBaseURL(srv.URL))
+	if got := describeError(err); !strings.HasPrefix(got, "network error on POST /oauth2/token: ") {
+		t.Errorf("login to a stopped server: %q", got)
+	}
+}
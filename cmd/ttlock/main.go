@@ -1,10 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"time"
 
-	"github.com/BurntSushi/toml"
 	"github.com/immofon/ttlock"
 	"github.com/urfave/cli/v2"
 )
@@ -12,41 +14,99 @@ import (
 var client *ttlock.Client
 
 func main() {
-	app := &cli.App{
+	if err := newApp().Run(os.Args); err != nil {
+		log.Fatal(describeError(err))
+	}
+}
+
+// newApp builds the ttlock command line application
+func newApp() *cli.App {
+	return &cli.App{
 		Name:  "ttlock",
 		Usage: "TTLock CLI",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    "config",
 				Aliases: []string{"c"},
 				Usage:   "Config toml file path",
-				Value:   "/tmp/ttlock.toml",
+				Value:   defaultConfigPath(),
+				EnvVars: []string{"TTLOCK_CONFIG"},
 			},
-		},
+			&cli.StringFlag{
+				Name:    "profile",
+				Aliases: []string{"p"},
+				Usage:   "Account profile from the config file (default: top-level account)",
+			},
+			&cli.StringFlag{
+				Name:  "lang",
+				Usage: "Language of messages (zh-CN, en)",
+				Value: "zh-CN",
+			},
+			&cli.StringFlag{
+				Name:  "record",
+				Usage: "Record API interactions to a cassette directory",
+			},
+			&cli.StringFlag{
+				Name:  "replay",
+				Usage: "Replay API interactions from a cassette directory instead of calling the API",
+			},
+		}, outputFlags...),
 		Before: func(ctx *cli.Context) error {
-			config_path := ctx.String("config")
-			var config Config
+			locale, err := ttlock.ParseLocale(ctx.String("lang"))
+			if err != nil {
+				return err
+			}
+
+			// Commands that do not talk to the API run without logging in
+			switch ctx.Args().First() {
+			case "", "help", "h", "config":
+				return nil
+			}
 
-			if _, err := os.Stat(config_path); err != nil {
-				log.Printf("failed to load config file: %v", err)
-				data, err := toml.Marshal(config)
-				if err != nil {
+			config, err := loadConfig(ctx.String("config"))
+			if err != nil {
+				return err
+			}
+
+			profile, err := config.profile(ctx.String("profile"))
+			if err != nil {
+				return err
+			}
+			if err := profile.resolve(); err != nil {
+				return err
+			}
+
+			cassette, err := openCassette(ctx.String("record"), ctx.String("replay"))
+			if err != nil {
+				return err
+			}
+			// Replayed logins do not need real credentials
+			if ctx.String("replay") == "" {
+				if err := profile.complete(); err != nil {
 					return err
 				}
-
-				return os.WriteFile(config_path, data, 0600)
 			}
-			if _, err := toml.DecodeFile(config_path, &config); err != nil {
+
+			opts, err := profile.clientOptions()
+			if err != nil {
 				return err
 			}
+			opts = append(opts, ttlock.WithLocale(locale))
+			if cassette != nil {
+				opts = append(opts, ttlock.WithHTTPClient(&http.Client{
+					Timeout:   10 * time.Second,
+					Transport: cassette,
+				}))
+			}
 
-			client = ttlock.NewClient(
-				config.ClientID,
-				config.ClientSecret,
-				config.Username,
-				config.Password,
+			client, err = ttlock.Login(
+				profile.ClientID,
+				profile.ClientSecret,
+				profile.Username,
+				profile.Password,
+				opts...,
 			)
-			return nil
+			return err
 		},
 		After: func(ctx *cli.Context) error {
 			return nil
@@ -56,12 +116,30 @@ func main() {
 			lockCmd,
 			listLockCmd,
 			listPasscodeCmd,
+			listKeyCmd,
 			genPassCmd,
 			sendKeyCmd,
+			sendKeysCmd,
+			passcodeCmd,
+			batteryCmd,
+			featuresCmd,
+			stayCmd,
+			applyCmd,
+			configCmd,
 		},
 	}
+}
 
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+// openCassette returns the cassette selected by the --record and --replay
+// flags, or nil if neither is set
+func openCassette(recordDir, replayDir string) (*ttlock.Cassette, error) {
+	switch {
+	case recordDir != "" && replayDir != "":
+		return nil, fmt.Errorf("--record and --replay cannot be used together")
+	case recordDir != "":
+		return ttlock.NewCassette(recordDir, ttlock.CassetteRecord, nil)
+	case replayDir != "":
+		return ttlock.NewCassette(replayDir, ttlock.CassetteReplay, nil)
 	}
+	return nil, nil
 }
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Default columns per resource, named by JSON field
+var (
+	lockColumns     = []string{"lockId", "lockAlias", "lockName", "electricQuantity", "groupName", "hasGateway"}
+	passcodeColumns = []string{"keyboardPwdId", "keyboardPwd", "keyboardPwdName", "keyboardPwdType", "startDate", "endDate"}
+	keyColumns      = []string{"keyId", "username", "keyName", "keyStatus", "startDate", "endDate", "keyRight"}
+)
+
+var outputFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "output",
+		Usage: "Output format (table, json, jsonl, csv); defaults to json, or table for tabular commands",
+	},
+	&cli.StringFlag{
+		Name:  "template",
+		Usage: "Go text/template applied to each item, e.g. '{{.LockID}} {{.LockAlias}}'",
+	},
+}
+
+// printValue prints a single object in the selected output format.
+// Table and CSV output list the object's fields one per row.
+func printValue(c *cli.Context, v interface{}) error {
+	if tmpl := c.String("template"); tmpl != "" {
+		return printTemplate(tmpl, []interface{}{v})
+	}
+
+	switch format := outputFormat(c, "json"); format {
+	case "json":
+		return printJSON(v)
+	case "jsonl":
+		return printJSONLine(v)
+	case "table", "csv":
+		keys, fields, err := jsonFields(v)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(keys))
+		for _, k := range keys {
+			rows = append(rows, []string{k, cellString(fields[k])})
+		}
+		return printRows(format, []string{"FIELD", "VALUE"}, rows)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// printText prints v as text unless an output format or template is
+// selected, in which case it is printed by printValue
+func printText(c *cli.Context, v fmt.Stringer) error {
+	if outputFormat(c, "") == "" && c.String("template") == "" {
+		fmt.Print(v)
+		return nil
+	}
+	return printValue(c, v)
+}
+
+// printList prints items (a slice) in the selected output format, using
+// columns for table and CSV output. In JSON format whole is printed instead
+// of items when it is not nil, e.g. to keep pagination fields.
+func printList(c *cli.Context, items interface{}, columns []string, whole interface{}, def string) error {
+	list := toSlice(items)
+
+	if tmpl := c.String("template"); tmpl != "" {
+		return printTemplate(tmpl, list)
+	}
+
+	switch format := outputFormat(c, def); format {
+	case "json":
+		if whole != nil {
+			return printJSON(whole)
+		}
+		return printJSON(items)
+	case "jsonl":
+		for _, item := range list {
+			if err := printJSONLine(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "table", "csv":
+		rows := make([][]string, 0, len(list))
+		for _, item := range list {
+			_, fields, err := jsonFields(item)
+			if err != nil {
+				return err
+			}
+			row := make([]string, len(columns))
+			for i, col := range columns {
+				row[i] = cellString(fields[col])
+			}
+			rows = append(rows, row)
+		}
+		return printRows(format, columns, rows)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// outputFormat returns the command's --format flag, the global --output flag or def.
+func outputFormat(c *cli.Context, def string) string {
+	if f := c.String("format"); f != "" {
+		return f
+	}
+	if f := c.String("output"); f != "" {
+		return f
+	}
+	return def
+}
+
+func printJSONLine(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func printTemplate(text string, items []interface{}) error {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	for _, item := range items {
+		if err := tmpl.Execute(os.Stdout, item); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func printRows(format string, header []string, rows [][]string) error {
+	if format == "csv" {
+		w := csv.NewWriter(os.Stdout)
+		w.Write(header)
+		w.WriteAll(rows)
+		return w.Error()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if i > 0 {
+				fmt.Fprint(w, "\t")
+			}
+			fmt.Fprint(w, cell)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+// toSlice converts any slice to []interface{}
+func toSlice(items interface{}) []interface{} {
+	rv := reflect.ValueOf(items)
+	if rv.Kind() != reflect.Slice {
+		return []interface{}{items}
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list
+}
+
+// jsonFields returns the top-level JSON fields of v in their encoded order
+func jsonFields(v interface{}) ([]string, map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("cannot print %T as a table", v)
+	}
+
+	var keys []string
+	fields := make(map[string]interface{})
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := tok.(string)
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		fields[key] = value
+	}
+	return keys, fields, nil
+}
+
+func cellString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/immofon/ttlock"
+	"github.com/urfave/cli/v2"
+)
+
+// keyGrantColumns are the columns of a sendkeys input file. Only lock,
+// receiver and key_name are required.
+var keyGrantColumns = []string{"lock", "receiver", "key_name", "start", "end", "remarks", "remote_enable", "key_right", "create_user"}
+
+var keyResultHeader = []string{"row", "lock", "lock_id", "receiver", "key_name", "key_id", "status", "error_code", "error_id", "error"}
+
+var sendKeysCmd = &cli.Command{
+	Name:  "sendkeys",
+	Usage: "Send eKeys listed in a CSV file",
+	Description: "The CSV file has a header row with the columns " + strings.Join(keyGrantColumns, ", ") + ".\n" +
+		"lock is a lock alias or ID. start and end are wall-clock times at the lock (YYYYMMDD-HH,\n" +
+		"YYYYMMDD-HHMM or YYYY-MM-DD HH:MM); leave both empty for a permanent key. remote_enable,\n" +
+		"key_right and create_user take yes or no. The result of every row is written to the results\n" +
+		"file. When it exists, rows that already have a key ID are skipped, so an interrupted or\n" +
+		"partly failed run can be repeated.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "file",
+			Aliases:  []string{"f"},
+			Required: true,
+			Usage:    "CSV file of the keys to send",
+		},
+		&cli.StringFlag{
+			Name:  "results",
+			Usage: "Results CSV file (default: the input file name with .results.csv)",
+		},
+		&cli.IntFlag{
+			Name:    "concurrency",
+			Aliases: []string{"j"},
+			Usage:   "Number of keys sent in parallel",
+			Value:   4,
+		},
+	},
+	Action: func(c *cli.Context) error {
+		input := c.String("file")
+		grants, err := readKeyGrants(input)
+		if err != nil {
+			return err
+		}
+
+		resultsPath := c.String("results")
+		if resultsPath == "" {
+			resultsPath = strings.TrimSuffix(input, ".csv") + ".results.csv"
+		}
+		done, err := readKeyResults(resultsPath)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Create(resultsPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w := csv.NewWriter(f)
+		w.Write(keyResultHeader)
+
+		// Rows sent by an earlier run are carried over; the rest are sent now
+		var pending []ttlock.KeyGrant
+		var rows []int
+		for i, g := range grants {
+			if record, ok := done[keyGrantIdentity(g)]; ok {
+				record[0] = strconv.Itoa(i + 1)
+				w.Write(record)
+				continue
+			}
+			pending = append(pending, g)
+			rows = append(rows, i+1)
+		}
+		w.Flush()
+
+		var sent, existing, failed int
+		_, err = client.SendKeys(c.Context, pending, ttlock.KeyBatchOptions{
+			Concurrency: c.Int("concurrency"),
+			Progress: func(r ttlock.KeyGrantResult) {
+				switch {
+				case r.Err != nil:
+					failed++
+				case r.Existing:
+					existing++
+				default:
+					sent++
+				}
+				w.Write(keyResultRecord(rows[r.Index], pending[r.Index], r))
+				w.Flush()
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if err := w.Error(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "%d sent, %d already on the lock, %d failed, %d done before; results in %s\n",
+			sent, existing, failed, len(grants)-len(pending), resultsPath)
+		if failed > 0 {
+			return cli.Exit("", 1)
+		}
+		return nil
+	},
+}
+
+// readKeyGrants reads a sendkeys input file
+func readKeyGrants(path string) ([]ttlock.KeyGrant, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: missing header row: %w", path, err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"lock", "receiver", "key_name"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s: missing column %q", path, name)
+		}
+	}
+
+	var grants []ttlock.KeyGrant
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return grants, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		g := ttlock.KeyGrant{
+			Lock:     field("lock"),
+			Receiver: field("receiver"),
+			KeyName:  field("key_name"),
+			Options:  ttlock.SendKeyOptions{Remarks: field("remarks")},
+		}
+		if g.Lock == "" || g.Receiver == "" || g.KeyName == "" {
+			return nil, fmt.Errorf("%s:%d: lock, receiver and key_name are required", path, line)
+		}
+		if g.Start, err = parseWallClock(field("start")); err != nil {
+			return nil, fmt.Errorf("%s:%d: start: %w", path, line, err)
+		}
+		if g.End, err = parseWallClock(field("end")); err != nil {
+			return nil, fmt.Errorf("%s:%d: end: %w", path, line, err)
+		}
+		for _, opt := range []struct {
+			column  string
+			value   *int
+			yes, no int
+		}{
+			{"remote_enable", &g.Options.RemoteEnable, 1, 2},
+			{"key_right", &g.Options.KeyRight, 1, 0},
+			{"create_user", &g.Options.CreateUser, 1, 2},
+		} {
+			if *opt.value, err = parseYesNo(field(opt.column), opt.yes, opt.no); err != nil {
+				return nil, fmt.Errorf("%s:%d: %s: %w", path, line, opt.column, err)
+			}
+		}
+		grants = append(grants, g)
+	}
+}
+
+// readKeyResults returns the records of rows with a key ID from an earlier
+// results file, by keyGrantIdentity. A missing file has none.
+func readKeyResults(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	done := make(map[string][]string)
+	for i, record := range records {
+		if i == 0 || len(record) != len(keyResultHeader) || record[5] == "" {
+			continue
+		}
+		done[strings.Join([]string{record[1], record[3], record[4]}, "\x00")] = record
+	}
+	return done, nil
+}
+
+// keyGrantIdentity identifies a row of the input across runs
+func keyGrantIdentity(g ttlock.KeyGrant) string {
+	return strings.Join([]string{g.Lock, g.Receiver, g.KeyName}, "\x00")
+}
+
+func keyResultRecord(row int, g ttlock.KeyGrant, r ttlock.KeyGrantResult) []string {
+	record := []string{strconv.Itoa(row), g.Lock, "", g.Receiver, g.KeyName, "", "sent", "", "", ""}
+	if r.LockID != 0 {
+		record[2] = strconv.Itoa(r.LockID)
+	}
+	switch {
+	case r.Err != nil:
+		record[6] = "failed"
+		record[9] = r.Err.Error()
+		var apiErr *ttlock.APIError
+		if errors.As(r.Err, &apiErr) {
+			record[7] = strconv.Itoa(int(apiErr.Code))
+			record[8] = apiErr.ID
+		}
+		return record
+	case r.Existing:
+		record[6] = "existing"
+	}
+	record[5] = strconv.Itoa(r.KeyID)
+	return record
+}
+
+// parseWallClock parses a wall-clock time of a sendkeys file. An empty
+// string gives the zero time.
+func parseWallClock(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"20060102-15", "20060102-1504", "2006-01-02 15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want YYYYMMDD-HH, YYYYMMDD-HHMM or YYYY-MM-DD HH:MM", s)
+}
+
+// parseYesNo maps yes/no to the API values of an option; empty leaves the
+// option unset (0)
+func parseYesNo(s string, yes, no int) (int, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return 0, nil
+	case "yes", "y", "true", "1":
+		return yes, nil
+	case "no", "n", "false", "0":
+		return no, nil
+	}
+	return 0, fmt.Errorf("invalid value %q: want yes or no", s)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/immofon/ttlock"
+	"github.com/urfave/cli/v2"
+)
+
+var stayCmd = &cli.Command{
+	Name:  "stay",
+	Usage: "Provision and revoke guest access for a stay",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "create",
+			Usage: "Issue a passcode and/or eKey for a stay and print its record",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:     "id",
+					Required: true,
+					Usage:    "Lock ID",
+				},
+				&cli.StringFlag{
+					Name:     "guest",
+					Required: true,
+					Usage:    "Guest name, used as the passcode and eKey name",
+				},
+				&cli.StringFlag{
+					Name:  "to",
+					Usage: "TTLock account of the guest; an eKey is sent only when set",
+				},
+				&cli.StringFlag{
+					Name:     "in",
+					Required: true,
+					Usage:    "Check-in time at the lock (now, YYYYMMDD-HH, YYYYMMDD-HHMM or RFC 3339)",
+				},
+				&cli.StringFlag{
+					Name:  "out",
+					Usage: "Check-out time at the lock; omit for an open-ended stay",
+				},
+				&cli.BoolFlag{
+					Name:  "no-passcode",
+					Usage: "Do not issue a passcode",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				lockID := c.Int("id")
+
+				detail, err := client.GetLockDetail(lockID)
+				if err != nil {
+					return err
+				}
+				loc := detail.Location()
+
+				checkIn, err := parseLockTime(c.String("in"), loc)
+				if err != nil {
+					return err
+				}
+				req := ttlock.StayRequest{
+					LockID:     lockID,
+					Guest:      c.String("guest"),
+					Username:   c.String("to"),
+					CheckIn:    checkIn,
+					NoPasscode: c.Bool("no-passcode"),
+				}
+				if s := c.String("out"); s != "" {
+					if req.CheckOut, err = parseLockTime(s, loc); err != nil {
+						return err
+					}
+				}
+
+				stay, err := client.ProvisionStay(req)
+				if err != nil {
+					return err
+				}
+				return printValue(c, stay)
+			},
+		},
+		{
+			Name:      "revoke",
+			Usage:     "Delete all credentials of a stay record",
+			ArgsUsage: "<record.json | ->",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return fmt.Errorf("expected one stay record file, or - for stdin")
+				}
+
+				var r io.Reader = os.Stdin
+				if name := c.Args().First(); name != "-" {
+					f, err := os.Open(name)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					r = f
+				}
+				var stay ttlock.Stay
+				if err := json.NewDecoder(r).Decode(&stay); err != nil {
+					return fmt.Errorf("invalid stay record: %w", err)
+				}
+
+				result, err := client.RevokeStay(&stay)
+				if perr := printValue(c, result); perr != nil {
+					return perr
+				}
+				return err
+			},
+		},
+	},
+}
@@ -0,0 +1,257 @@
+package ttlock
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CyclicSchedule describes a recurring passcode window: the days of the week
+// it applies to and a daily hour range in the lock's time zone.
+type CyclicSchedule struct {
+	Days      []time.Weekday
+	StartHour int // 0-23, inclusive
+	EndHour   int // 1-24, exclusive; must be after StartHour (24, midnight, only for eKeys)
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) >= 3 {
+		if d, ok := weekdayNames[s[:3]]; ok {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
+
+// ParseCyclicSchedule parses a schedule such as "Mon-Fri 08-18", "Sat,Sun 10-16",
+// "Wed 09-12" or "Daily 00-06". Day ranges may wrap around the week ("Fri-Mon").
+// An eKey schedule may end at midnight ("Sat 18-24").
+func ParseCyclicSchedule(s string) (CyclicSchedule, error) {
+	var cs CyclicSchedule
+
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return cs, fmt.Errorf("invalid cyclic schedule %q: want \"<days> <HH-HH>\"", s)
+	}
+
+	if strings.EqualFold(fields[0], "daily") {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			cs.Days = append(cs.Days, d)
+		}
+	} else {
+		for _, part := range strings.Split(fields[0], ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			first, err := parseWeekday(from)
+			if err != nil {
+				return cs, err
+			}
+			if !isRange {
+				cs.Days = append(cs.Days, first)
+				continue
+			}
+			last, err := parseWeekday(to)
+			if err != nil {
+				return cs, err
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				cs.Days = append(cs.Days, d)
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return cs, fmt.Errorf("invalid hour range %q: want HH-HH", fields[1])
+	}
+	var err error
+	if cs.StartHour, err = strconv.Atoi(from); err != nil {
+		return cs, fmt.Errorf("invalid start hour %q", from)
+	}
+	if cs.EndHour, err = strconv.Atoi(to); err != nil {
+		return cs, fmt.Errorf("invalid end hour %q", to)
+	}
+
+	return cs, cs.validate()
+}
+
+func (s CyclicSchedule) validate() error {
+	if len(s.Days) == 0 {
+		return fmt.Errorf("cyclic schedule has no days")
+	}
+	if s.StartHour < 0 || s.StartHour > 23 || s.EndHour < 1 || s.EndHour > 24 {
+		return fmt.Errorf("cyclic hours must be within 00-24, got %02d-%02d", s.StartHour, s.EndHour)
+	}
+	if s.EndHour <= s.StartHour {
+		return fmt.Errorf("cyclic end hour %02d is not after start hour %02d", s.EndHour, s.StartHour)
+	}
+	return nil
+}
+
+// validatePasscode validates a schedule for a cyclic passcode, whose end
+// hour is carried in the hour of a timestamp and so cannot be 24
+func (s CyclicSchedule) validatePasscode() error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	if s.EndHour > 23 {
+		return fmt.Errorf("cyclic passcodes end at hour 23 at the latest; %02d-24 is only valid for eKeys", s.StartHour)
+	}
+	return nil
+}
+
+// daySet returns the schedule's days as a bit set indexed by time.Weekday.
+func (s CyclicSchedule) daySet() uint8 {
+	var set uint8
+	for _, d := range s.Days {
+		set |= 1 << uint(d)
+	}
+	return set
+}
+
+const (
+	weekendDays uint8 = 1<<time.Saturday | 1<<time.Sunday
+	workdayDays uint8 = 1<<time.Monday | 1<<time.Tuesday | 1<<time.Wednesday | 1<<time.Thursday | 1<<time.Friday
+	allDays           = weekendDays | workdayDays
+)
+
+// PasscodeType returns the cyclic PasscodeType matching the schedule's days.
+// The lock only knows daily, weekend, workday and single-weekday cycles, so
+// any other combination of days is an error.
+func (s CyclicSchedule) PasscodeType() (PasscodeType, error) {
+	if err := s.validatePasscode(); err != nil {
+		return 0, err
+	}
+
+	switch set := s.daySet(); set {
+	case allDays:
+		return PasscodeTypeDailyCyclic, nil
+	case weekendDays:
+		return PasscodeTypeWeekendCyclic, nil
+	case workdayDays:
+		return PasscodeTypeWorkdayCyclic, nil
+	default:
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if set == 1<<uint(d) {
+				return weekdayPasscodeTypes[d], nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no single cyclic passcode type covers %s", s.daysString())
+}
+
+var weekdayPasscodeTypes = map[time.Weekday]PasscodeType{
+	time.Monday:    PasscodeTypeMondayCyclic,
+	time.Tuesday:   PasscodeTypeTuesdayCyclic,
+	time.Wednesday: PasscodeTypeWednesdayCyclic,
+	time.Thursday:  PasscodeTypeThursdayCyclic,
+	time.Friday:    PasscodeTypeFridayCyclic,
+	time.Saturday:  PasscodeTypeSaturdayCyclic,
+	time.Sunday:    PasscodeTypeSundayCyclic,
+}
+
+func (s CyclicSchedule) daysString() string {
+	days := append([]time.Weekday(nil), s.Days...)
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	names := make([]string, 0, len(days))
+	for i, d := range days {
+		if i > 0 && days[i-1] == d {
+			continue
+		}
+		names = append(names, d.String()[:3])
+	}
+	return strings.Join(names, ",")
+}
+
+func (s CyclicSchedule) String() string {
+	return fmt.Sprintf("%s %02d-%02d", s.daysString(), s.StartHour, s.EndHour)
+}
+
+// Dates encodes the schedule into the startDate/endDate pair expected by the API.
+// The hour of each timestamp carries the daily window, while the calendar dates
+// bound the overall validity. from and until are wall-clock dates at the lock;
+// only their year, month and day are used.
+func (s CyclicSchedule) Dates(loc *time.Location, from, until time.Time) (startDate, endDate int64, err error) {
+	if err := s.validatePasscode(); err != nil {
+		return 0, 0, err
+	}
+	start := time.Date(from.Year(), from.Month(), from.Day(), s.StartHour, 0, 0, 0, loc)
+	end := time.Date(until.Year(), until.Month(), until.Day(), s.EndHour, 0, 0, 0, loc)
+	if !end.After(start) {
+		return 0, 0, fmt.Errorf("cyclic validity ends %s before it starts %s", end.Format("2006-01-02"), start.Format("2006-01-02"))
+	}
+	return start.UnixMilli(), end.UnixMilli(), nil
+}
+
+// CyclicKeyPeriod is a weekly period of a cyclic eKey, in the lock's time zone.
+type CyclicKeyPeriod struct {
+	Weekday   int `json:"weekDay"`   // 1 (Monday) to 7 (Sunday)
+	StartTime int `json:"startTime"` // minutes after midnight
+	EndTime   int `json:"endTime"`   // minutes after midnight, exclusive
+}
+
+// KeyPeriods returns the weekly periods of a cyclic eKey following the
+// schedule. Unlike cyclic passcodes, eKeys accept any combination of days and
+// an EndHour of 24, which gives an EndTime of 1440.
+func (s CyclicSchedule) KeyPeriods() ([]CyclicKeyPeriod, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	var periods []CyclicKeyPeriod
+	for d := time.Monday; d <= time.Saturday+1; d++ {
+		if s.daySet()&(1<<uint(d%7)) == 0 {
+			continue
+		}
+		periods = append(periods, CyclicKeyPeriod{
+			Weekday:   int(d), // Sunday is 7
+			StartTime: s.StartHour * 60,
+			EndTime:   s.EndHour * 60,
+		})
+	}
+	return periods, nil
+}
+
+// GetCyclicPasscode issues a cyclic random passcode following schedule between
+// the dates of from and until (wall-clock dates at the lock).
+// It returns ErrLockOperationNotSupported if the lock lacks LockFeatureCyclicPasscode.
+func (c *Client) GetCyclicPasscode(lockID int, pwdName string, schedule CyclicSchedule, from, until time.Time) (*RandomPasscodeResponse, error) {
+	return GetCyclicPasscode(c, lockID, pwdName, schedule, from, until)
+}
+
+// GetCyclicPasscode issues a cyclic passcode through s, like Client.GetCyclicPasscode.
+func GetCyclicPasscode(s Service, lockID int, pwdName string, schedule CyclicSchedule, from, until time.Time) (*RandomPasscodeResponse, error) {
+	pwdType, err := schedule.PasscodeType()
+	if err != nil {
+		return nil, err
+	}
+
+	detail, err := s.GetLockDetail(lockID)
+	if err != nil {
+		return nil, err
+	}
+	if !detail.SupportsFeature(LockFeatureCyclicPasscode) {
+		return nil, serviceError(s, ErrLockOperationNotSupported)
+	}
+
+	startDate, endDate, err := schedule.Dates(detail.Location(), from, until)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRandomPasscode(lockID, pwdType, pwdName, startDate, endDate)
+}
@@ -0,0 +1,89 @@
+package ttlock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCyclicSchedulePasscodeType(t *testing.T) {
+	for input, want := range map[string]PasscodeType{
+		"Daily 00-06":   PasscodeTypeDailyCyclic,
+		"Mon-Fri 08-18": PasscodeTypeWorkdayCyclic,
+		"Sat,Sun 10-16": PasscodeTypeWeekendCyclic,
+		"Sun-Sat 07-08": PasscodeTypeDailyCyclic,
+		"Wed 09-12":     PasscodeTypeWednesdayCyclic,
+	} {
+		s, err := ParseCyclicSchedule(input)
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if got, err := s.PasscodeType(); err != nil || got != want {
+			t.Errorf("%s: type %d, %v; want %d", input, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"Mon,Wed 08-18", "Mon-Fri 18-08", "Mon-Fri 08-24", "Funday 08-18", "Mon-Fri"} {
+		s, err := ParseCyclicSchedule(bad)
+		if err == nil {
+			_, err = s.PasscodeType()
+		}
+		if err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
+
+func TestCyclicScheduleDatesAtTheLock(t *testing.T) {
+	s, err := ParseCyclicSchedule("Mon-Fri 08-18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := time.FixedZone("UTC+1", 3600)
+	from := time.Date(2030, 11, 1, 23, 0, 0, 0, loc)
+	until := time.Date(2030, 12, 1, 0, 0, 0, 0, loc)
+	start, end, err := s.Dates(loc, from, until)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2030, 11, 1, 7, 0, 0, 0, time.UTC); !time.UnixMilli(start).Equal(want) {
+		t.Errorf("start %v, want %v", time.UnixMilli(start).UTC(), want)
+	}
+	if want := time.Date(2030, 12, 1, 17, 0, 0, 0, time.UTC); !time.UnixMilli(end).Equal(want) {
+		t.Errorf("end %v, want %v", time.UnixMilli(end).UTC(), want)
+	}
+
+	if _, _, err := s.Dates(loc, until, from); err == nil {
+		t.Error("no error for an end date before the start date")
+	}
+}
+
+func TestCyclicKeyScheduleEndsAtMidnight(t *testing.T) {
+	s, err := ParseCyclicSchedule("Sat,Sun 18-24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	periods, err := s.KeyPeriods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CyclicKeyPeriod{{6, 18 * 60, 1440}, {7, 18 * 60, 1440}}
+	if len(periods) != len(want) || periods[0] != want[0] || periods[1] != want[1] {
+		t.Errorf("periods %v, want %v", periods, want)
+	}
+
+	// Cyclic passcodes carry the end hour in a timestamp
+	if _, err := s.PasscodeType(); err == nil {
+		t.Error("PasscodeType accepted an end hour of 24")
+	}
+	day := time.Date(2030, 11, 2, 0, 0, 0, 0, time.UTC)
+	if _, _, err := s.Dates(time.UTC, day, day); err == nil {
+		t.Error("Dates accepted an end hour of 24")
+	}
+
+	for _, bad := range []string{"Sat 18-25", "Sat 24-24", "Sat 00-00"} {
+		if _, err := ParseCyclicSchedule(bad); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package ttlock
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
 
 // ErrorCode represents a TTLock API error code
 type ErrorCode int
@@ -145,32 +150,382 @@ var errorMessages = map[ErrorCode]string{
 	ErrFingerprintNotExist: "该指纹已不存在",
 }
 
+// errorIDs holds the stable machine-readable identifier of each error code.
+var errorIDs = map[ErrorCode]string{
+	// Common
+	ErrOperationFailed:       "operation_failed",
+	ErrClientIDNotExist:      "client_id_not_exist",
+	ErrInvalidClient:         "invalid_client",
+	ErrTokenNotExist:         "token_not_exist",
+	ErrTokenUnauthorized:     "token_unauthorized",
+	ErrInvalidUsernameOrPass: "invalid_username_or_password",
+	ErrInvalidRefreshToken:   "invalid_refresh_token",
+	ErrNotLockAdmin:          "not_lock_admin",
+	ErrInvalidUsernameFormat: "invalid_username_format",
+	ErrUserAlreadyExists:     "user_already_exists",
+	ErrInvalidDeleteUserID:   "invalid_delete_user_id",
+	ErrPasswordMustBeMD5:     "password_must_be_md5",
+	ErrRateLimitExceeded:     "rate_limit_exceeded",
+	ErrInvalidRequestTime:    "invalid_request_time",
+	ErrInvalidJSONFormat:     "invalid_json_format",
+	ErrSystemInternalError:   "system_internal_error",
+	ErrInvalidParameter:      "invalid_parameter",
+	ErrPermissionDenied:      "permission_denied",
+	ErrDeleteOrTransferLocks: "delete_or_transfer_locks",
+
+	// Lock
+	ErrLockNotExist:              "lock_not_exist",
+	ErrLockFrozen:                "lock_frozen",
+	ErrCannotTransferLockToSelf:  "cannot_transfer_lock_to_self",
+	ErrLockOperationNotSupported: "lock_operation_not_supported",
+	ErrStorageFull:               "storage_full",
+	ErrNBDeviceNotRegistered:     "nb_device_not_registered",
+	ErrAutoLockTimeLimitExceeded: "auto_lock_time_limit_exceeded",
+
+	// eKey
+	ErrKeyNotExist:                  "key_not_exist",
+	ErrGroupNameExists:              "group_name_exists",
+	ErrGroupNotExist:                "group_not_exist",
+	ErrAccountBoundCannotReceiveKey: "account_bound_cannot_receive_key",
+	ErrCannotSendKeyToSelf:          "cannot_send_key_to_self",
+	ErrCannotSendKeyToAdmin:         "cannot_send_key_to_admin",
+	ErrCannotModifyKeyValidity:      "cannot_modify_key_validity",
+	ErrReceiverNotRegistered:        "receiver_not_registered",
+
+	// Passcode
+	ErrLockNoPasscodeData:         "lock_no_passcode_data",
+	ErrPasscodeNotExist:           "passcode_not_exist",
+	ErrInvalidPasscodeLength:      "invalid_passcode_length",
+	ErrPasscodeAlreadyExists:      "passcode_already_exists",
+	ErrCannotModifyUnusedPasscode: "cannot_modify_unused_passcode",
+	ErrCustomPasscodeSpaceFull:    "custom_passcode_space_full",
+
+	// Gateway & WiFi Lock
+	ErrNoAvailableGateway:          "no_available_gateway",
+	ErrGatewayOffline:              "gateway_offline",
+	ErrGatewayBusy:                 "gateway_busy",
+	ErrCannotTransferGatewayToSelf: "cannot_transfer_gateway_to_self",
+	ErrWifiLockNotConfigured:       "wifi_lock_not_configured",
+	ErrWifiInPowerSavingMode:       "wifi_in_power_saving_mode",
+	ErrLockOffline:                 "lock_offline",
+	ErrLockBusy:                    "lock_busy",
+	ErrGatewayNotExist:             "gateway_not_exist",
+
+	// IC Card & Fingerprint
+	ErrICCardNotExist:      "ic_card_not_exist",
+	ErrFingerprintNotExist: "fingerprint_not_exist",
+}
+
+// ID returns the stable identifier of the code, such as "gateway_offline".
+// Unknown codes are named "error_<code>".
+func (code ErrorCode) ID() string {
+	if id, ok := errorIDs[code]; ok {
+		return id
+	}
+	return fmt.Sprintf("error_%d", int(code))
+}
+
+// Message returns the description of the code in the given locale.
+func (code ErrorCode) Message(locale Locale) string {
+	messages := errorMessages
+	if locale.normalize() == LocaleEN {
+		messages = errorMessagesEN
+	}
+	if msg, ok := messages[code]; ok {
+		return msg
+	}
+	return "unknown error"
+}
+
+// Error makes ErrorCode usable as a sentinel, e.g. errors.Is(err, ttlock.ErrLockFrozen).
+func (code ErrorCode) Error() string {
+	return fmt.Sprintf("ttlock error %d: %s", code, code.Message(LocaleZhCN))
+}
+
+// ErrorCategory groups related error codes
+type ErrorCategory string
+
+const (
+	CategoryUnknown  ErrorCategory = "unknown"
+	CategoryAuth     ErrorCategory = "auth"     // client credentials, tokens and login
+	CategoryRequest  ErrorCategory = "request"  // malformed or rejected requests
+	CategoryServer   ErrorCategory = "server"   // server side failures and limits
+	CategoryAccount  ErrorCategory = "account"  // user registration and permissions
+	CategoryLock     ErrorCategory = "lock"     // lock state and operations
+	CategoryKey      ErrorCategory = "key"      // eKeys and groups
+	CategoryPasscode ErrorCategory = "passcode" // keyboard passcodes
+	CategoryGateway  ErrorCategory = "gateway"  // gateways and WiFi locks
+	CategoryCard     ErrorCategory = "card"     // IC cards and fingerprints
+)
+
+var errorCategories = map[ErrorCode]ErrorCategory{
+	// Common
+	ErrOperationFailed:       CategoryServer,
+	ErrClientIDNotExist:      CategoryAuth,
+	ErrInvalidClient:         CategoryAuth,
+	ErrTokenNotExist:         CategoryAuth,
+	ErrTokenUnauthorized:     CategoryAuth,
+	ErrInvalidUsernameOrPass: CategoryAuth,
+	ErrInvalidRefreshToken:   CategoryAuth,
+	ErrNotLockAdmin:          CategoryAccount,
+	ErrInvalidUsernameFormat: CategoryAccount,
+	ErrUserAlreadyExists:     CategoryAccount,
+	ErrInvalidDeleteUserID:   CategoryAccount,
+	ErrPasswordMustBeMD5:     CategoryRequest,
+	ErrRateLimitExceeded:     CategoryServer,
+	ErrInvalidRequestTime:    CategoryRequest,
+	ErrInvalidJSONFormat:     CategoryRequest,
+	ErrSystemInternalError:   CategoryServer,
+	ErrInvalidParameter:      CategoryRequest,
+	ErrPermissionDenied:      CategoryAccount,
+	ErrDeleteOrTransferLocks: CategoryAccount,
+
+	// Lock
+	ErrLockNotExist:              CategoryLock,
+	ErrLockFrozen:                CategoryLock,
+	ErrCannotTransferLockToSelf:  CategoryLock,
+	ErrLockOperationNotSupported: CategoryLock,
+	ErrStorageFull:               CategoryLock,
+	ErrNBDeviceNotRegistered:     CategoryLock,
+	ErrAutoLockTimeLimitExceeded: CategoryLock,
+
+	// eKey
+	ErrKeyNotExist:                  CategoryKey,
+	ErrGroupNameExists:              CategoryKey,
+	ErrGroupNotExist:                CategoryKey,
+	ErrAccountBoundCannotReceiveKey: CategoryKey,
+	ErrCannotSendKeyToSelf:          CategoryKey,
+	ErrCannotSendKeyToAdmin:         CategoryKey,
+	ErrCannotModifyKeyValidity:      CategoryKey,
+	ErrReceiverNotRegistered:        CategoryKey,
+
+	// Passcode
+	ErrLockNoPasscodeData:         CategoryPasscode,
+	ErrPasscodeNotExist:           CategoryPasscode,
+	ErrInvalidPasscodeLength:      CategoryPasscode,
+	ErrPasscodeAlreadyExists:      CategoryPasscode,
+	ErrCannotModifyUnusedPasscode: CategoryPasscode,
+	ErrCustomPasscodeSpaceFull:    CategoryPasscode,
+
+	// Gateway & WiFi Lock
+	ErrNoAvailableGateway:          CategoryGateway,
+	ErrGatewayOffline:              CategoryGateway,
+	ErrGatewayBusy:                 CategoryGateway,
+	ErrCannotTransferGatewayToSelf: CategoryGateway,
+	ErrWifiLockNotConfigured:       CategoryGateway,
+	ErrWifiInPowerSavingMode:       CategoryGateway,
+	ErrLockOffline:                 CategoryGateway,
+	ErrLockBusy:                    CategoryGateway,
+	ErrGatewayNotExist:             CategoryGateway,
+
+	// IC Card & Fingerprint
+	ErrICCardNotExist:      CategoryCard,
+	ErrFingerprintNotExist: CategoryCard,
+}
+
+// retryableErrors are transient conditions where repeating the request later may succeed
+var retryableErrors = map[ErrorCode]bool{
+	ErrRateLimitExceeded:   true,
+	ErrInvalidRequestTime:  true,
+	ErrSystemInternalError: true,
+	ErrGatewayBusy:         true,
+	ErrLockBusy:            true,
+}
+
+// notFoundErrors report that the addressed object does not exist (any more)
+var notFoundErrors = map[ErrorCode]bool{
+	ErrClientIDNotExist:    true,
+	ErrTokenNotExist:       true,
+	ErrLockNotExist:        true,
+	ErrKeyNotExist:         true,
+	ErrGroupNotExist:       true,
+	ErrLockNoPasscodeData:  true,
+	ErrPasscodeNotExist:    true,
+	ErrGatewayNotExist:     true,
+	ErrICCardNotExist:      true,
+	ErrFingerprintNotExist: true,
+}
+
+// Category returns the category of the code, or CategoryUnknown.
+func (code ErrorCode) Category() ErrorCategory {
+	if cat, ok := errorCategories[code]; ok {
+		return cat
+	}
+	return CategoryUnknown
+}
+
+// Known reports whether the code is part of the documented catalogue.
+func (code ErrorCode) Known() bool {
+	_, ok := errorCategories[code]
+	return ok
+}
+
 // Error represents a TTLock API error
 type Error struct {
 	Code    ErrorCode
-	Message string
+	ID      string // stable identifier, see ErrorCode.ID
+	Message string // description in the client's locale, or Errmsg for unknown codes
+	Errmsg  string // message returned by the server, if any
 }
 
 func (e *Error) Error() string {
 	return fmt.Sprintf("ttlock error %d: %s", e.Code, e.Message)
 }
 
-// NewError creates a new Error from a code
-func NewError(code ErrorCode) *Error {
-	msg, ok := errorMessages[code]
-	if !ok {
-		msg = "unknown error"
+// Is reports whether target is the same error code, so errors.Is works with
+// both ErrorCode sentinels and *Error values through wrapped errors.
+func (e *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case ErrorCode:
+		return e.Code == t
+	case *Error:
+		return t != nil && e.Code == t.Code
 	}
+	return false
+}
+
+// Category returns the category of the error code.
+func (e *Error) Category() ErrorCategory {
+	return e.Code.Category()
+}
+
+// NewError creates a new Error from a code with a Chinese message
+func NewError(code ErrorCode) *Error {
+	return NewLocalizedError(code, LocaleZhCN)
+}
+
+// NewLocalizedError creates a new Error from a code with a message in the given locale
+func NewLocalizedError(code ErrorCode, locale Locale) *Error {
 	return &Error{
 		Code:    code,
-		Message: msg,
+		ID:      code.ID(),
+		Message: code.Message(locale),
+	}
+}
+
+// newResponseError creates an Error from the errcode/errmsg of an API response.
+// The server message is kept, and used as Message when the code is unknown.
+func newResponseError(errcode int, errmsg string, locale Locale) *Error {
+	e := NewLocalizedError(ErrorCode(errcode), locale)
+	e.Errmsg = errmsg
+	if !e.Code.Known() && errmsg != "" {
+		e.Message = errmsg
 	}
+	return e
 }
 
-// IsErrorCode checks if an error corresponds to a specific ErrorCode
+// asError extracts the *Error from err's chain.
+func asError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	var code ErrorCode
+	if errors.As(err, &code) {
+		return NewError(code), true
+	}
+	return nil, false
+}
+
+// IsErrorCode checks if an error, or any error it wraps, corresponds to a specific ErrorCode
 func IsErrorCode(err error, code ErrorCode) bool {
-	if e, ok := err.(*Error); ok {
-		return e.Code == code
+	return errors.Is(err, code)
+}
+
+// IsAuthError reports whether err is caused by invalid client credentials, tokens or login.
+func IsAuthError(err error) bool {
+	e, ok := asError(err)
+	return ok && e.Category() == CategoryAuth
+}
+
+// IsGatewayError reports whether err is caused by a gateway or WiFi lock being unavailable.
+func IsGatewayError(err error) bool {
+	e, ok := asError(err)
+	return ok && e.Category() == CategoryGateway
+}
+
+// IsRetryable reports whether err is transient, so the request may succeed when repeated later.
+func IsRetryable(err error) bool {
+	e, ok := asError(err)
+	return ok && retryableErrors[e.Code]
+}
+
+// IsNotFound reports whether err says that the addressed object does not exist.
+func IsNotFound(err error) bool {
+	e, ok := asError(err)
+	return ok && notFoundErrors[e.Code]
+}
+
+// APIError is the error returned when the API answers with a non-zero errcode.
+type APIError = Error
+
+// maxErrorBody is the number of response bytes kept in HTTPError and DecodeError messages
+const maxErrorBody = 512
+
+func truncateBody(body []byte) string {
+	if len(body) > maxErrorBody {
+		return string(body[:maxErrorBody]) + "..."
 	}
-	return false
+	return string(body)
+}
+
+// HTTPError is returned when the server answers with a non-2xx HTTP status,
+// for example an HTML error page from a proxy.
+type HTTPError struct {
+	StatusCode int
+	Method     string
+	Path       string // request path without the query, which carries the access token
+	Body       string // response body, truncated to 512 bytes
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("ttlock: %s %s: HTTP %d %s: %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// NetworkError is returned when a request gets no response, for example
+// because of a timeout or a refused connection. Err is the transport error
+// with the query removed from any URL it contains, so it can be logged
+// without leaking the access token.
+type NetworkError struct {
+	Method string
+	Path   string // request path without the query
+	Err    error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("ttlock: %s %s: %v", e.Method, e.Path, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// newNetworkError wraps a transport error of req, scrubbing the query of the URL
+func newNetworkError(req *http.Request, err error) *NetworkError {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		scrubbed := *urlErr
+		if u, perr := url.Parse(urlErr.URL); perr == nil {
+			u.RawQuery, u.User = "", nil
+			scrubbed.URL = u.String()
+		} else {
+			scrubbed.URL = req.URL.Path
+		}
+		err = &scrubbed
+	}
+	return &NetworkError{Method: req.Method, Path: req.URL.Path, Err: err}
+}
+
+// DecodeError is returned when a response body is not the expected JSON.
+type DecodeError struct {
+	Raw []byte // complete response body
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode response: %v: %s", e.Err, truncateBody(e.Raw))
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
 }
@@ -0,0 +1,98 @@
+package ttlock
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Every documented code must have an ID, both messages and a category, or
+// it decodes as "unknown error" and the predicates cannot match it.
+func TestErrorCatalogueComplete(t *testing.T) {
+	for code := range errorCategories {
+		if _, ok := errorIDs[code]; !ok {
+			t.Errorf("%d has no ID", code)
+		}
+		if _, ok := errorMessages[code]; !ok {
+			t.Errorf("%d has no Chinese message", code)
+		}
+		if _, ok := errorMessagesEN[code]; !ok {
+			t.Errorf("%d has no English message", code)
+		}
+	}
+	for name, m := range map[string]map[ErrorCode]string{"ID": errorIDs, "message": errorMessages, "English message": errorMessagesEN} {
+		for code := range m {
+			if !code.Known() {
+				t.Errorf("%d has a %s but no category", code, name)
+			}
+		}
+	}
+	for _, set := range []map[ErrorCode]bool{retryableErrors, notFoundErrors} {
+		for code := range set {
+			if !code.Known() {
+				t.Errorf("%d is classified but has no category", code)
+			}
+		}
+	}
+
+	ids := make(map[string]ErrorCode)
+	for code, id := range errorIDs {
+		if other, ok := ids[id]; ok {
+			t.Errorf("%d and %d share the ID %q", code, other, id)
+		}
+		ids[id] = code
+	}
+}
+
+// documentedErrors is the error code table of the TTLock open platform docs,
+// section by section.
+var documentedErrors = [][]ErrorCode{
+	{1, 10000, 10001, 10003, 10004, 10007, 10011, 20002, 30002, 30003, 30004, 30005, 30006, 80000, 80002, 90000, -3, -2018, -4063},
+	{-1003, -2025, -3011, -4043, -4056, -4067, -4082},
+	{-1008, -1016, -1018, -1027, -2019, -2020, -2023, -4064},
+	{-1007, -2009, -3006, -3007, -3008, -3009},
+	{-2012, -3002, -3003, -3016, -3034, -3035, -3036, -3037, -4037},
+	{-1021, -1023},
+}
+
+func TestErrorCatalogueMatchesDocs(t *testing.T) {
+	documented := make(map[ErrorCode]bool)
+	for _, section := range documentedErrors {
+		for _, code := range section {
+			documented[code] = true
+			if !code.Known() {
+				t.Errorf("documented code %d is not catalogued", code)
+			}
+		}
+	}
+	for code := range errorCategories {
+		if !documented[code] {
+			t.Errorf("%d is catalogued but not documented", code)
+		}
+	}
+}
+
+func TestErrorPredicatesThroughWrapping(t *testing.T) {
+	err := fmt.Errorf("revoke: %w", newResponseError(int(ErrGatewayOffline), "gateway is offline", LocaleEN))
+
+	if !errors.Is(err, ErrGatewayOffline) || !IsErrorCode(err, ErrGatewayOffline) {
+		t.Error("wrapped error does not match its code")
+	}
+	if errors.Is(err, ErrLockOffline) {
+		t.Error("wrapped error matches another code")
+	}
+	if !IsGatewayError(err) || IsAuthError(err) || IsNotFound(err) || IsRetryable(err) {
+		t.Error("wrong category predicates")
+	}
+	if !IsNotFound(fmt.Errorf("x: %w", ErrKeyNotExist)) {
+		t.Error("bare ErrorCode sentinel is not classified")
+	}
+	if !IsRetryable(NewError(ErrRateLimitExceeded)) {
+		t.Error("rate limit is not retryable")
+	}
+
+	unknown := newResponseError(-99999, "something new", LocaleEN)
+	if unknown.Message != "something new" || unknown.ID != "error_-99999" || unknown.Category() != CategoryUnknown {
+		t.Errorf("unknown code decoded as %+v", unknown)
+	}
+}
@@ -1,9 +1,5 @@
 package ttlock
 
-import (
-	"math/big"
-)
-
 // LockFeature represents a specific capability of a lock
 type LockFeature int
 
@@ -218,16 +214,14 @@ func (f LockFeature) String() string {
 }
 
 // HasFeature checks if the given feature value string supports the specified feature.
-// The featureValue is a hexadecimal string.
+// The featureValue is a hexadecimal string. A malformed value supports nothing;
+// use ParseFeatureSet to detect it.
 func HasFeature(featureValue string, feature LockFeature) bool {
-	if featureValue == "" {
+	set, err := ParseFeatureSet(featureValue)
+	if err != nil {
 		return false
 	}
-	val := new(big.Int)
-	// Parse the hex string
-	val.SetString(featureValue, 16)
-	// Check the bit at the feature position
-	return val.Bit(int(feature)) == 1
+	return set.Has(feature)
 }
 
 // SupportsFeature checks if the lock supports the specified feature.
@@ -0,0 +1,248 @@
+package ttlock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// featureNames holds the stable machine-readable identifier of each known feature.
+var featureNames = map[LockFeature]string{
+	LockFeaturePasscode:                "passcode",
+	LockFeatureICCard:                  "ic_card",
+	LockFeatureFingerprint:             "fingerprint",
+	LockFeatureWristband:               "wristband",
+	LockFeatureAutoLock:                "auto_lock",
+	LockFeaturePasscodeDelete:          "passcode_delete",
+	LockFeatureFirmwareUpgrade:         "firmware_upgrade",
+	LockFeaturePasscodeManagement:      "passcode_management",
+	LockFeatureLockCommand:             "lock_command",
+	LockFeaturePasscodeVisible:         "passcode_visible",
+	LockFeatureGatewayUnlock:           "gateway_unlock",
+	LockFeatureFreeze:                  "freeze",
+	LockFeatureCyclicPasscode:          "cyclic_passcode",
+	LockFeatureDoorSensor:              "door_sensor",
+	LockFeatureRemoteUnlockConfig:      "remote_unlock_config",
+	LockFeatureAudioManagement:         "audio_management",
+	LockFeatureNB:                      "nb",
+	LockFeatureAdminPasscode:           "admin_passcode",
+	LockFeatureHotelCard:               "hotel_card",
+	LockFeatureNoClockChip:             "no_clock_chip",
+	LockFeatureNoBroadcast:             "no_broadcast",
+	LockFeaturePassageMode:             "passage_mode",
+	LockFeatureTurnOffAutoLock:         "turn_off_auto_lock",
+	LockFeatureWirelessKeypad:          "wireless_keypad",
+	LockFeatureLightTime:               "light_time",
+	LockFeatureHotelCardBlacklist:      "hotel_card_blacklist",
+	LockFeatureIdentityCard:            "identity_card",
+	LockFeatureTamperAlert:             "tamper_alert",
+	LockFeatureResetButton:             "reset_button",
+	LockFeaturePrivacyLock:             "privacy_lock",
+	LockFeatureDeadLock:                "dead_lock",
+	LockFeaturePassageModeException:    "passage_mode_exception",
+	LockFeatureCyclicICOrFingerprint:   "cyclic_ic_or_fingerprint",
+	LockFeaturePrivacyMode:             "privacy_mode",
+	LockFeatureLeftRightOpen:           "left_right_open",
+	LockFeatureFingerVein:              "finger_vein",
+	LockFeatureTelinkBluetooth:         "telink_bluetooth",
+	LockFeatureNBActivation:            "nb_activation",
+	LockFeatureRecoverCyclicPasscode:   "recover_cyclic_passcode",
+	LockFeatureWirelessKey:             "wireless_key",
+	LockFeatureAccessoryBattery:        "accessory_battery",
+	LockFeatureSoundVolumeLanguage:     "sound_volume_language",
+	LockFeatureQRCode:                  "qr_code",
+	LockFeatureDoorSensorState:         "door_sensor_state",
+	LockFeaturePassageModeAutoUnlock:   "passage_mode_auto_unlock",
+	LockFeatureFingerprintDistribution: "fingerprint_distribution",
+	LockFeatureZhongZhengFingerprint:   "zhongzheng_fingerprint",
+	LockFeatureShengYuanFingerprint:    "shengyuan_fingerprint",
+	LockFeatureWirelessDoorSensor:      "wireless_door_sensor",
+	LockFeatureDoorUnclosedAlarm:       "door_unclosed_alarm",
+	LockFeatureProximitySensor:         "proximity_sensor",
+	LockFeature3DFace:                  "3d_face",
+	LockFeatureAutoLockPairing:         "auto_lock_pairing",
+	LockFeatureCPUCard:                 "cpu_card",
+	LockFeatureWiFi:                    "wifi",
+	LockFeatureWiFiStaticIP:            "wifi_static_ip",
+	LockFeatureIncompletePasscode:      "incomplete_passcode",
+	LockFeatureDoubleAuth:              "double_auth",
+	LockFeatureXiongMaiVideo:           "xiongmai_video",
+	LockFeatureZhiAnFace:               "zhian_face",
+	LockFeaturePalmVein:                "palm_vein",
+	LockFeatureOneTimeQRCode:           "one_time_qr_code",
+	LockFeatureThirdPartyBluetooth:     "third_party_bluetooth",
+	LockFeatureWiFiPowerSave:           "wifi_power_save",
+	LockFeatureMultifuncWirelessKeypad: "multifunc_wireless_keypad",
+	LockFeatureCustomQRCode:            "custom_qr_code",
+}
+
+// featuresByName is the reverse of featureNames.
+var featuresByName = func() map[string]LockFeature {
+	m := make(map[string]LockFeature, len(featureNames))
+	for f, name := range featureNames {
+		m[name] = f
+	}
+	return m
+}()
+
+// KnownFeatures returns all features defined by this package in bit order.
+func KnownFeatures() []LockFeature {
+	features := make([]LockFeature, 0, len(featureNames))
+	for f := range featureNames {
+		features = append(features, f)
+	}
+	sort.Slice(features, func(i, j int) bool { return features[i] < features[j] })
+	return features
+}
+
+// Known reports whether the feature is defined by this package.
+func (f LockFeature) Known() bool {
+	_, ok := featureNames[f]
+	return ok
+}
+
+// Name returns the stable identifier of the feature, such as "cyclic_passcode".
+// Unknown features are named "bit_<n>".
+func (f LockFeature) Name() string {
+	if name, ok := featureNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("bit_%d", int(f))
+}
+
+// FeatureSet is a parsed lock featureValue.
+// The zero value is an empty set.
+type FeatureSet struct {
+	bits *big.Int
+}
+
+// ParseFeatureSet parses a hexadecimal featureValue as returned by the API.
+// An empty string yields an empty set.
+func ParseFeatureSet(featureValue string) (FeatureSet, error) {
+	if featureValue == "" {
+		return FeatureSet{}, nil
+	}
+	bits, ok := new(big.Int).SetString(featureValue, 16)
+	if !ok || bits.Sign() < 0 {
+		return FeatureSet{}, fmt.Errorf("invalid feature value %q: not a hexadecimal number", featureValue)
+	}
+	return FeatureSet{bits: bits}, nil
+}
+
+// maxFeatureBit is the highest bit accepted when building a set. Real
+// featureValues use a few dozen bits; the limit keeps decoded input from
+// allocating arbitrarily large numbers.
+const maxFeatureBit = 1023
+
+// validBit reports whether f can be stored in a FeatureSet
+func (f LockFeature) validBit() bool {
+	return f >= 0 && f <= maxFeatureBit
+}
+
+// NewFeatureSet returns a set containing the given features. Features below
+// 0 or above bit 1023 are ignored.
+func NewFeatureSet(features ...LockFeature) FeatureSet {
+	bits := new(big.Int)
+	for _, f := range features {
+		if f.validBit() {
+			bits.SetBit(bits, int(f), 1)
+		}
+	}
+	return FeatureSet{bits: bits}
+}
+
+// Has reports whether the set contains the feature.
+func (s FeatureSet) Has(feature LockFeature) bool {
+	if s.bits == nil || feature < 0 {
+		return false
+	}
+	return s.bits.Bit(int(feature)) == 1
+}
+
+// Features returns all features in the set in bit order, including bits this
+// package does not know about.
+func (s FeatureSet) Features() []LockFeature {
+	if s.bits == nil {
+		return nil
+	}
+	var features []LockFeature
+	for i := 0; i < s.bits.BitLen(); i++ {
+		if s.bits.Bit(i) == 1 {
+			features = append(features, LockFeature(i))
+		}
+	}
+	return features
+}
+
+// Equal reports whether both sets contain the same features.
+func (s FeatureSet) Equal(other FeatureSet) bool {
+	return s.int().Cmp(other.int()) == 0
+}
+
+// Diff returns the features present in other but not in s (added) and those
+// present in s but not in other (removed).
+func (s FeatureSet) Diff(other FeatureSet) (added, removed []LockFeature) {
+	a, b := s.int(), other.int()
+	added = FeatureSet{bits: new(big.Int).AndNot(b, a)}.Features()
+	removed = FeatureSet{bits: new(big.Int).AndNot(a, b)}.Features()
+	return added, removed
+}
+
+func (s FeatureSet) int() *big.Int {
+	if s.bits == nil {
+		return new(big.Int)
+	}
+	return s.bits
+}
+
+// String returns the featureValue encoding of the set.
+func (s FeatureSet) String() string {
+	return strings.ToUpper(s.int().Text(16))
+}
+
+// MarshalJSON encodes the set as a list of feature names.
+func (s FeatureSet) MarshalJSON() ([]byte, error) {
+	names := []string{}
+	for _, f := range s.Features() {
+		names = append(names, f.Name())
+	}
+	return json.Marshal(names)
+}
+
+// UnmarshalJSON decodes a list of feature names as produced by MarshalJSON.
+func (s *FeatureSet) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	bits := new(big.Int)
+	for _, name := range names {
+		f, ok := featuresByName[name]
+		if !ok {
+			digits, isBit := strings.CutPrefix(name, "bit_")
+			n, err := strconv.Atoi(digits)
+			if !isBit || err != nil {
+				return fmt.Errorf("unknown lock feature %q", name)
+			}
+			if f = LockFeature(n); !f.validBit() {
+				return fmt.Errorf("lock feature %q is out of range 0-%d", name, maxFeatureBit)
+			}
+		}
+		bits.SetBit(bits, int(f), 1)
+	}
+	s.bits = bits
+	return nil
+}
+
+// Features parses the lock's FeatureValue.
+func (l *Lock) Features() (FeatureSet, error) {
+	return ParseFeatureSet(l.FeatureValue)
+}
+
+// Features parses the lock detail's FeatureValue.
+func (l *LockDetail) Features() (FeatureSet, error) {
+	return ParseFeatureSet(l.FeatureValue)
+}
@@ -0,0 +1,35 @@
+package ttlock
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFeatureSetJSONRoundTrip(t *testing.T) {
+	set := NewFeatureSet(LockFeaturePasscode, LockFeatureCyclicPasscode, LockFeature(200))
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded FeatureSet
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(set) {
+		t.Errorf("decoded %s, want %s", decoded, set)
+	}
+}
+
+func TestFeatureSetRejectsBadBits(t *testing.T) {
+	for _, input := range []string{`["bit_-1"]`, `["bit_2000000000"]`, `["bit_5x"]`, `["bit_"]`, `["no_such_feature"]`} {
+		var set FeatureSet
+		if err := json.Unmarshal([]byte(input), &set); err == nil {
+			t.Errorf("%s: no error", input)
+		}
+	}
+
+	set := NewFeatureSet(LockFeature(-1), LockFeature(maxFeatureBit+1), LockFeaturePasscode)
+	if got := set.Features(); len(got) != 1 || got[0] != LockFeaturePasscode {
+		t.Errorf("features %v, want only the passcode feature", got)
+	}
+}
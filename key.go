@@ -1,14 +1,16 @@
 package ttlock
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"iter"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
-	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // SendKeyResponse represents the response for sending an eKey
@@ -18,12 +20,66 @@ type SendKeyResponse struct {
 	Errmsg  string `json:"errmsg"`
 }
 
+// Key represents an eKey object
+type Key struct {
+	KeyID          int    `json:"keyId"`          // 钥匙ID
+	LockID         int    `json:"lockId"`         // 锁ID
+	UID            int    `json:"uid"`            // 钥匙接收者的用户ID
+	Username       string `json:"username"`       // 钥匙接收者的用户名
+	KeyName        string `json:"keyName"`        // 钥匙名称
+	KeyStatus      string `json:"keyStatus"`      // 钥匙状态：110401-正常使用、110402-待接收、110405-已冻结、110408-已删除、110410-已重置
+	StartDate      int64  `json:"startDate"`      // 有效期开始时间（毫秒时间戳），0 表示永久
+	EndDate        int64  `json:"endDate"`        // 有效期结束时间（毫秒时间戳），0 表示永久
+	KeyRight       int    `json:"keyRight"`       // 是否授权管理员钥匙：1-是、0-否
+	RemoteEnable   int    `json:"remoteEnable"`   // 是否支持远程开锁：1-是、2-否
+	Remarks        string `json:"remarks"`        // 备注
+	SenderUsername string `json:"senderUsername"` // 发送者用户名
+	Date           int64  `json:"date"`           // 发送时间（毫秒时间戳）
+}
+
+// KeyListResponse represents the response for the eKey list API
+type KeyListResponse struct {
+	List     []Key  `json:"list"`
+	PageNo   int    `json:"pageNo"`
+	PageSize int    `json:"pageSize"`
+	Pages    int    `json:"pages"`
+	Total    int    `json:"total"`
+	Errcode  int    `json:"errcode"`
+	Errmsg   string `json:"errmsg"`
+}
+
 // SendKeyOptions contains optional parameters for SendKey
 type SendKeyOptions struct {
 	Remarks      string // 备注，留言
 	RemoteEnable int    // 是否支持远程开锁：1-是、2-否
 	KeyRight     int    // 是否授权管理员钥匙：1-是、0-否，默认0不授权
 	CreateUser   int    // 是否自动创建通通锁账号：1-是、2-否(默认2)，仅receiverUsername为邮箱或手机号时生效
+
+	// Cyclic makes the key a cyclic key (keyType 4), valid only in these
+	// weekly periods between startDate and endDate. See CyclicSchedule.KeyPeriods.
+	Cyclic []CyclicKeyPeriod
+}
+
+// Key types of SendKey
+const (
+	KeyTypePeriod = 1 // 限时钥匙，startDate和endDate均为0时为永久钥匙
+	KeyTypeCyclic = 4 // 循环钥匙
+)
+
+// maxKeyNameLength is the longest key name accepted by SendKey, in characters
+const maxKeyNameLength = 50
+
+// validateKeyName checks a key name before it is sent
+func validateKeyName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("key name is required")
+	case utf8.RuneCountInString(name) > maxKeyNameLength:
+		return fmt.Errorf("key name %q is longer than %d characters", name, maxKeyNameLength)
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return fmt.Errorf("key name %q contains control characters", name)
+	}
+	return nil
 }
 
 // SendKey sends an eKey to a user.
@@ -33,22 +89,40 @@ type SendKeyOptions struct {
 // - lockID: 锁ID，由锁初始化接口生成
 // - receiverUsername: 接收方用户名
 // - keyName: 钥匙名
-// - startDate: 有效期开始时间，时间戳(毫秒)
+// - startDate: 有效期开始时间，时间戳(毫秒)，与endDate均为0时为永久钥匙
 // - endDate: 有效期结束时间，时间戳(毫秒)
-// - options: 选填参数，包含remarks/remoteEnable/keyRight/createUser
+// - options: 选填参数，包含remarks/remoteEnable/keyRight/createUser，以及循环钥匙的周期
 // - date: 当前时间(毫秒时间戳，由方法内部自动添加)
+//
+// The key name and the period are checked before the request is sent.
+// A cyclic key needs a start and end date.
 func (c *Client) SendKey(lockID int, receiverUsername, keyName string, startDate, endDate int64, options *SendKeyOptions) (*SendKeyResponse, error) {
+	if err := validateKeyName(keyName); err != nil {
+		return nil, err
+	}
+	permanent := startDate == 0 && endDate == 0
+	if !permanent && endDate <= startDate {
+		return nil, fmt.Errorf("key end date %d is not after start date %d", endDate, startDate)
+	}
+	if options != nil && len(options.Cyclic) > 0 && permanent {
+		return nil, fmt.Errorf("a cyclic key needs a start and end date")
+	}
+
+	accessToken, err := c.accessToken()
+	if err != nil {
+		return nil, err
+	}
 	endpoint := c.BaseURL + "/v3/key/send"
 
 	data := url.Values{}
 	data.Set("clientId", c.ClientID)
-	data.Set("accessToken", c.AccessToken())
+	data.Set("accessToken", accessToken)
 	data.Set("lockId", strconv.Itoa(lockID))
 	data.Set("receiverUsername", receiverUsername)
 	data.Set("keyName", keyName)
 	data.Set("startDate", strconv.FormatInt(startDate, 10))
 	data.Set("endDate", strconv.FormatInt(endDate, 10))
-	data.Set("date", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	data.Set("date", c.date())
 
 	if options != nil {
 		if options.Remarks != "" {
@@ -66,6 +140,14 @@ func (c *Client) SendKey(lockID int, receiverUsername, keyName string, startDate
 		if options.CreateUser != 0 {
 			data.Set("createUser", strconv.Itoa(options.CreateUser))
 		}
+		if len(options.Cyclic) > 0 {
+			config, err := json.Marshal(options.Cyclic)
+			if err != nil {
+				return nil, err
+			}
+			data.Set("keyType", strconv.Itoa(KeyTypeCyclic))
+			data.Set("cyclicConfig", string(config))
+		}
 	}
 
 	req, err := http.NewRequest("POST", endpoint, strings.NewReader(data.Encode()))
@@ -75,25 +157,136 @@ func (c *Client) SendKey(lockID int, receiverUsername, keyName string, startDate
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.HTTPClient.Do(req)
+	var result SendKeyResponse
+	if err := c.do(req, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// DeleteKey deletes an eKey.
+func (c *Client) DeleteKey(keyID int) error {
+	accessToken, err := c.accessToken()
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
+	endpoint := c.BaseURL + "/v3/key/delete"
+
+	data := url.Values{}
+	data.Set("clientId", c.ClientID)
+	data.Set("accessToken", accessToken)
+	data.Set("keyId", strconv.Itoa(keyID))
+	data.Set("date", c.date())
 
-	body, err := io.ReadAll(resp.Body)
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(data.Encode()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	var result SendKeyResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var result apiStatus
+	return c.do(req, &result)
+}
+
+// ChangeKeyPeriod changes the validity period of an eKey.
+// startDate and endDate are timestamps in milliseconds; pass 0 for both to
+// make the key permanent.
+func (c *Client) ChangeKeyPeriod(keyID int, startDate, endDate int64) error {
+	accessToken, err := c.accessToken()
+	if err != nil {
+		return err
+	}
+	endpoint := c.BaseURL + "/v3/key/changePeriod"
+
+	data := url.Values{}
+	data.Set("clientId", c.ClientID)
+	data.Set("accessToken", accessToken)
+	data.Set("keyId", strconv.Itoa(keyID))
+	data.Set("startDate", strconv.FormatInt(startDate, 10))
+	data.Set("endDate", strconv.FormatInt(endDate, 10))
+	data.Set("date", c.date())
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var result apiStatus
+	return c.do(req, &result)
+}
+
+// GetKeyList retrieves the list of eKeys of a lock.
+// searchStr is an optional filter on the receiver username or key name. Pass empty string to ignore.
+func (c *Client) GetKeyList(lockID, pageNo, pageSize int, searchStr string) (*KeyListResponse, error) {
+	accessToken, err := c.accessToken()
+	if err != nil {
+		return nil, err
 	}
+	endpoint := c.BaseURL + "/v3/lock/listKey"
+
+	params := url.Values{}
+	params.Set("clientId", c.ClientID)
+	params.Set("accessToken", accessToken)
+	params.Set("lockId", strconv.Itoa(lockID))
+	params.Set("pageNo", strconv.Itoa(pageNo))
+	params.Set("pageSize", strconv.Itoa(pageSize))
+	params.Set("date", c.date())
 
-	if result.Errcode != 0 {
-		return nil, NewError(ErrorCode(result.Errcode))
+	if searchStr != "" {
+		params.Set("searchStr", searchStr)
+	}
+
+	req, err := http.NewRequest("GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var result KeyListResponse
+	if err := c.do(req, &result); err != nil {
+		return nil, err
 	}
 
 	return &result, nil
 }
+
+// KeyIterator allows iterating over eKeys without manually handling pagination
+type KeyIterator = Pager[Key]
+
+// IterateKeys creates a new iterator for the eKeys of a lock.
+func (c *Client) IterateKeys(lockID int, searchStr string) *KeyIterator {
+	return IterateKeys(c, lockID, searchStr)
+}
+
+// IterateKeys creates a new iterator for the eKeys of s, like Client.IterateKeys.
+func IterateKeys(s KeyService, lockID int, searchStr string) *KeyIterator {
+	return NewPager(func(pageNo, pageSize int) ([]Key, int, error) {
+		resp, err := s.GetKeyList(lockID, pageNo, pageSize, searchStr)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.List, resp.Pages, nil
+	}, func(k Key) int { return k.KeyID })
+}
+
+// KeyFilter holds the optional parameters of the eKey list.
+type KeyFilter struct {
+	SearchStr string
+	PageSize  int // 0 means DefaultPageSize
+
+	// Concurrency is the number of pages fetched in parallel; 0 or 1 fetches sequentially.
+	Concurrency int
+}
+
+// Keys returns a range-over-func iterator over all eKeys of a lock.
+func (c *Client) Keys(ctx context.Context, lockID int, filter KeyFilter) iter.Seq2[Key, error] {
+	return Keys(ctx, c, lockID, filter)
+}
+
+// Keys returns a range-over-func iterator over the eKeys of s, like Client.Keys.
+func Keys(ctx context.Context, s KeyService, lockID int, filter KeyFilter) iter.Seq2[Key, error] {
+	return IterateKeys(s, lockID, filter.SearchStr).SetPageSize(filter.PageSize).SetConcurrency(filter.Concurrency).All(ctx)
+}
@@ -0,0 +1,231 @@
+package ttlock
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeyGrant is one eKey of a SendKeys batch.
+type KeyGrant struct {
+	Lock     string // lock alias, or lock ID
+	Receiver string // receiver username
+	KeyName  string
+
+	// Start and End are wall-clock times at the lock; their zone is ignored.
+	// Leave both zero for a permanent key.
+	Start time.Time
+	End   time.Time
+
+	Options SendKeyOptions
+}
+
+// KeyGrantResult is the outcome of one KeyGrant.
+type KeyGrantResult struct {
+	Index    int // position of the grant in the batch
+	LockID   int // 0 if the lock could not be resolved
+	KeyID    int
+	Existing bool  // the lock already had an active key of this name for the receiver
+	Err      error // nil on success
+}
+
+// KeyBatchOptions configures SendKeys.
+type KeyBatchOptions struct {
+	// Concurrency is the number of keys sent in parallel; 0 or 1 sends sequentially.
+	Concurrency int
+
+	// Progress, if set, is called with each result as soon as it is known.
+	// Calls are not concurrent, so it may write to a file without locking.
+	Progress func(KeyGrantResult)
+}
+
+// SendKeys sends the eKeys of a batch and returns one result per grant, in
+// order. Lock aliases are resolved through the lock list. Grants whose key
+// already exists on the lock are not sent again, so a batch that was
+// interrupted can simply be run again. A grant repeating the lock, receiver
+// and key name of an earlier grant fails instead of sending a second key.
+// A failed grant does not stop the others; its error is in its result.
+// Only ctx's error is returned.
+func (c *Client) SendKeys(ctx context.Context, grants []KeyGrant, opts KeyBatchOptions) ([]KeyGrantResult, error) {
+	return SendKeys(ctx, c, grants, opts)
+}
+
+// SendKeys sends a batch of eKeys through s, like Client.SendKeys.
+func SendKeys(ctx context.Context, s Service, grants []KeyGrant, opts KeyBatchOptions) ([]KeyGrantResult, error) {
+	results := make([]KeyGrantResult, len(grants))
+	for i := range results {
+		results[i].Index = i
+	}
+
+	var mu sync.Mutex // serializes Progress
+	report := func(r KeyGrantResult) {
+		results[r.Index] = r
+		if opts.Progress != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			opts.Progress(r)
+		}
+	}
+
+	locks, err := prepareKeyBatch(ctx, s, grants)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := max(opts.Concurrency, 1)
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, g := range grants {
+		if err := ctx.Err(); err != nil {
+			wg.Wait()
+			return results, err
+		}
+
+		lock := locks[g.Lock]
+		id := keyIdentity{g.Receiver, g.KeyName}
+		r := KeyGrantResult{Index: i, LockID: lock.id}
+		switch first, dup := lock.batched[id]; {
+		case lock.err != nil:
+			r.Err = lock.err
+			report(r)
+			continue
+		case lock.existing[id] != 0:
+			r.KeyID, r.Existing = lock.existing[id], true
+			report(r)
+			continue
+		case dup:
+			r.Err = fmt.Errorf("same lock, receiver and key name as grant %d", first)
+			report(r)
+			continue
+		}
+		lock.batched[id] = i
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return results, ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			r.KeyID, r.Err = sendGrant(ctx, s, lock, g)
+			report(r)
+		}()
+	}
+	wg.Wait()
+	return results, ctx.Err()
+}
+
+type keyIdentity struct {
+	receiver, keyName string
+}
+
+// batchLock is a lock of a batch as resolved by prepareKeyBatch
+type batchLock struct {
+	id       int
+	loc      *time.Location
+	existing map[keyIdentity]int // key ID of active keys
+	batched  map[keyIdentity]int // index of the grant sending each key
+	err      error
+}
+
+// prepareKeyBatch resolves the locks of grants. Errors of single locks are
+// kept in their batchLock; only listing the locks can fail the batch.
+func prepareKeyBatch(ctx context.Context, s Service, grants []KeyGrant) (map[string]*batchLock, error) {
+	locks := make(map[string]*batchLock)
+	byID := make(map[int]*batchLock)
+	var aliases map[string][]int
+
+	for _, g := range grants {
+		if _, ok := locks[g.Lock]; ok {
+			continue
+		}
+
+		id, err := strconv.Atoi(g.Lock)
+		if err != nil {
+			if aliases == nil {
+				aliases = make(map[string][]int)
+				for lock, err := range Locks(ctx, s, LockFilter{}) {
+					if err != nil {
+						return nil, err
+					}
+					aliases[lock.LockAlias] = append(aliases[lock.LockAlias], lock.LockID)
+				}
+			}
+			switch matches := aliases[g.Lock]; len(matches) {
+			case 0:
+				locks[g.Lock] = &batchLock{err: fmt.Errorf("lock %q: no lock has this alias", g.Lock)}
+				continue
+			case 1:
+				id = matches[0]
+			default:
+				locks[g.Lock] = &batchLock{err: fmt.Errorf("lock %q: alias matches %d locks; use the lock ID", g.Lock, len(matches))}
+				continue
+			}
+		}
+
+		// An alias and the ID of the same lock share one entry
+		if lock, ok := byID[id]; ok {
+			locks[g.Lock] = lock
+			continue
+		}
+		lock := &batchLock{id: id, existing: make(map[keyIdentity]int), batched: make(map[keyIdentity]int)}
+		byID[id], locks[g.Lock] = lock, lock
+
+		detail, err := s.GetLockDetail(id)
+		if err != nil {
+			lock.err = err
+			continue
+		}
+		lock.loc = detail.Location()
+		for k, err := range Keys(ctx, s, id, KeyFilter{}) {
+			if err != nil {
+				lock.err = err
+				break
+			}
+			if !inactiveKeyStatuses[k.KeyStatus] {
+				lock.existing[keyIdentity{k.Username, k.KeyName}] = k.KeyID
+			}
+		}
+	}
+	return locks, nil
+}
+
+// sendGrant sends one key, waiting and retrying while the server reports
+// that the call rate limit is exceeded, unless ctx is done
+func sendGrant(ctx context.Context, s KeyService, lock *batchLock, g KeyGrant) (int, error) {
+	var startDate, endDate int64
+	switch {
+	case g.Start.IsZero() && g.End.IsZero():
+	case g.Start.IsZero() || g.End.IsZero():
+		return 0, fmt.Errorf("set both start and end, or neither for a permanent key")
+	default:
+		startDate = inLocation(g.Start, lock.loc).UnixMilli()
+		endDate = inLocation(g.End, lock.loc).UnixMilli()
+		if endDate <= startDate {
+			return 0, fmt.Errorf("end is not after start")
+		}
+	}
+
+	const maxRetries = 4
+	for attempt := 0; ; attempt++ {
+		resp, err := s.SendKey(lock.id, g.Receiver, g.KeyName, startDate, endDate, &g.Options)
+		if attempt < maxRetries && IsErrorCode(err, ErrRateLimitExceeded) {
+			timer := time.NewTimer(time.Second << attempt)
+			select {
+			case <-timer.C:
+				continue
+			case <-ctx.Done():
+				timer.Stop()
+				return 0, ctx.Err()
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		return resp.KeyID, nil
+	}
+}
@@ -0,0 +1,137 @@
+package ttlock_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/immofon/ttlock"
+)
+
+func TestSendKeysResumes(t *testing.T) {
+	srv, c := newTestServer(t)
+	for _, u := range []string{"r1", "r2", "r3"} {
+		srv.AddUser(u, "pw")
+	}
+	lockID := srv.AddLock(passcodeLock("Lobby"))
+	srv.AddLock(passcodeLock("Twin"))
+	srv.AddLock(passcodeLock("Twin"))
+	ctx := context.Background()
+
+	grants := []ttlock.KeyGrant{
+		{Lock: "Lobby", Receiver: "r1", KeyName: "Flat 1"},
+		{Lock: strconv.Itoa(lockID), Receiver: "r2", KeyName: "Flat 2",
+			Start: wallClock("2030-11-01 10:00"), End: wallClock("2030-12-01 10:00")},
+		{Lock: "Lobby", Receiver: "nobody", KeyName: "Flat 3"},
+		{Lock: "Twin", Receiver: "r3", KeyName: "Flat 4"},
+		{Lock: "Lobby", Receiver: "r3", KeyName: "Flat 5"},
+	}
+	// One send fails transiently
+	srv.InjectError("/v3/key/send", ttlock.ErrSystemInternalError, 1)
+
+	var reported int
+	results, err := c.SendKeys(ctx, grants, ttlock.KeyBatchOptions{
+		Concurrency: 1,
+		Progress:    func(ttlock.KeyGrantResult) { reported++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reported != len(grants) {
+		t.Errorf("%d progress reports, want %d", reported, len(grants))
+	}
+	if !ttlock.IsErrorCode(results[0].Err, ttlock.ErrSystemInternalError) {
+		t.Errorf("grant 0: %v, want the injected error", results[0].Err)
+	}
+	if results[1].Err != nil || results[1].KeyID == 0 || results[1].LockID != lockID {
+		t.Errorf("grant 1: %+v", results[1])
+	}
+	if !ttlock.IsErrorCode(results[2].Err, ttlock.ErrReceiverNotRegistered) {
+		t.Errorf("grant 2: %v, want ErrReceiverNotRegistered", results[2].Err)
+	}
+	if results[3].Err == nil || results[3].LockID != 0 {
+		t.Errorf("grant 3 with an ambiguous alias: %+v", results[3])
+	}
+
+	// A rerun sends only the failed grant and finds the others on the lock
+	results, err = c.SendKeys(ctx, grants, ttlock.KeyBatchOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || results[0].Existing {
+		t.Errorf("grant 0 on rerun: %+v", results[0])
+	}
+	for _, i := range []int{1, 4} {
+		if !results[i].Existing || results[i].KeyID == 0 {
+			t.Errorf("grant %d on rerun: %+v", i, results[i])
+		}
+	}
+	if n := len(srv.Keys(lockID)); n != 3 {
+		t.Errorf("%d keys on the lock, want 3", n)
+	}
+}
+
+func TestSendKeysStopsOnCancel(t *testing.T) {
+	srv, c := newTestServer(t)
+	srv.AddUser("r1", "pw")
+	srv.AddLock(passcodeLock("Lobby"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.SendKeys(ctx, []ttlock.KeyGrant{{Lock: "Lobby", Receiver: "r1", KeyName: "Flat 1"}}, ttlock.KeyBatchOptions{})
+	if err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if n := srv.Requests("/v3/key/send"); n != 0 {
+		t.Errorf("%d keys sent after cancel", n)
+	}
+}
+
+func TestSendKeysCancelsBackoff(t *testing.T) {
+	srv, c := newTestServer(t)
+	srv.AddUser("r1", "pw")
+	srv.AddUser("r2", "pw")
+	lockID := srv.AddLock(passcodeLock("Lobby"))
+	srv.InjectError("/v3/key/send", ttlock.ErrRateLimitExceeded, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	results, err := c.SendKeys(ctx, []ttlock.KeyGrant{
+		{Lock: strconv.Itoa(lockID), Receiver: "r1", KeyName: "Flat 1"},
+		{Lock: strconv.Itoa(lockID), Receiver: "r2", KeyName: "Flat 2"},
+	}, ttlock.KeyBatchOptions{})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("SendKeys returned %v after the deadline", d)
+	}
+	if results[0].Err != context.DeadlineExceeded {
+		t.Errorf("grant 0: %v", results[0].Err)
+	}
+	if n := srv.Requests("/v3/key/send"); n != 1 {
+		t.Errorf("%d sends, want 1", n)
+	}
+}
+
+func TestSendKeysRejectsDuplicates(t *testing.T) {
+	srv, c := newTestServer(t)
+	srv.AddUser("r1", "pw")
+	lockID := srv.AddLock(passcodeLock("Lobby"))
+
+	results, err := c.SendKeys(context.Background(), []ttlock.KeyGrant{
+		{Lock: "Lobby", Receiver: "r1", KeyName: "Flat 1"},
+		{Lock: strconv.Itoa(lockID), Receiver: "r1", KeyName: "Flat 1"},
+	}, ttlock.KeyBatchOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || results[1].Err == nil {
+		t.Errorf("results %+v", results)
+	}
+	if n := len(srv.Keys(lockID)); n != 1 {
+		t.Errorf("%d keys on the lock, want 1", n)
+	}
+}
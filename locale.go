@@ -0,0 +1,182 @@
+package ttlock
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Locale selects the language of feature descriptions and error messages
+type Locale string
+
+const (
+	LocaleZhCN Locale = "zh-CN" // 简体中文（默认）
+	LocaleEN   Locale = "en"    // English
+)
+
+// ParseLocale accepts language tags such as "en", "en-US", "zh" or "zh_CN".
+func ParseLocale(s string) (Locale, error) {
+	if l := Locale(s).normalize(); l != "" {
+		return l, nil
+	}
+	return "", fmt.Errorf("unsupported locale %q: want %s or %s", s, LocaleZhCN, LocaleEN)
+}
+
+// normalize maps a language tag to a supported Locale. The empty locale is
+// treated as LocaleZhCN; unsupported tags yield "".
+func (l Locale) normalize() Locale {
+	tag := strings.ToLower(strings.ReplaceAll(string(l), "_", "-"))
+	switch {
+	case tag == "":
+		return LocaleZhCN
+	case tag == "en" || strings.HasPrefix(tag, "en-"):
+		return LocaleEN
+	case tag == "zh" || strings.HasPrefix(tag, "zh-"):
+		return LocaleZhCN
+	}
+	return ""
+}
+
+// Description returns the description of the feature in the given locale.
+func (f LockFeature) Description(locale Locale) string {
+	if locale.normalize() != LocaleEN {
+		return f.String()
+	}
+	if desc, ok := featureDescriptionsEN[f]; ok {
+		return desc
+	}
+	return "unknown feature"
+}
+
+var errorMessagesEN = map[ErrorCode]string{
+	// Common
+	ErrOperationFailed:       "operation failed",
+	ErrClientIDNotExist:      "client_id does not exist",
+	ErrInvalidClient:         "invalid client: wrong client_id or client_secret",
+	ErrTokenNotExist:         "token does not exist",
+	ErrTokenUnauthorized:     "token unauthorized: the token has expired or been revoked",
+	ErrInvalidUsernameOrPass: "wrong username or password",
+	ErrInvalidRefreshToken:   "invalid refresh_token",
+	ErrNotLockAdmin:          "not the lock administrator",
+	ErrInvalidUsernameFormat: "username may only contain letters and digits",
+	ErrUserAlreadyExists:     "user already exists",
+	ErrInvalidDeleteUserID:   "invalid user to delete: only accounts registered by this application can be deleted",
+	ErrPasswordMustBeMD5:     "password must be MD5 hashed",
+	ErrRateLimitExceeded:     "API call rate limit exceeded",
+	ErrInvalidRequestTime:    "request time must be within five minutes of the current time",
+	ErrInvalidJSONFormat:     "invalid JSON format",
+	ErrSystemInternalError:   "internal system error",
+	ErrInvalidParameter:      "invalid parameter",
+	ErrPermissionDenied:      "permission denied: many APIs only accept the lock's super or authorized administrator, some accept any valid eKey user; request with an access token obtained by an eligible account",
+	ErrDeleteOrTransferLocks: "delete or transfer all locks of the account first",
+
+	// Lock
+	ErrLockNotExist:              "lock does not exist",
+	ErrLockFrozen:                "lock is frozen and cannot be operated",
+	ErrCannotTransferLockToSelf:  "cannot transfer a lock to yourself",
+	ErrLockOperationNotSupported: "the lock does not support this operation",
+	ErrStorageFull:               "storage is full, operation failed",
+	ErrNBDeviceNotRegistered:     "NB device is not registered, NB operations are unavailable",
+	ErrAutoLockTimeLimitExceeded: "auto-lock time out of range",
+
+	// eKey
+	ErrKeyNotExist:                  "eKey does not exist",
+	ErrGroupNameExists:              "group name already exists, please choose another",
+	ErrGroupNotExist:                "group does not exist",
+	ErrAccountBoundCannotReceiveKey: "this account is bound to another account and cannot receive eKeys",
+	ErrCannotSendKeyToSelf:          "cannot send an eKey to your own account",
+	ErrCannotSendKeyToAdmin:         "cannot send an eKey to the administrator",
+	ErrCannotModifyKeyValidity:      "the eKey validity period cannot be changed now",
+	ErrReceiverNotRegistered:        "send failed: the receiver account is not registered, please register and retry",
+
+	// Passcode
+	ErrLockNoPasscodeData:         "the lock has no passcode data",
+	ErrPasscodeNotExist:           "passcode does not exist",
+	ErrInvalidPasscodeLength:      "invalid passcode length, must be 4-9 digits",
+	ErrPasscodeAlreadyExists:      "the same passcode already exists, please choose another",
+	ErrCannotModifyUnusedPasscode: "cannot modify a passcode that has never been used on the lock",
+	ErrCustomPasscodeSpaceFull:    "custom passcode storage is full, delete unused passcodes and retry",
+
+	// Gateway & WiFi Lock
+	ErrNoAvailableGateway:          "no gateway available near the lock",
+	ErrGatewayOffline:              "gateway is offline, please check and retry",
+	ErrGatewayBusy:                 "gateway is busy, please retry later",
+	ErrCannotTransferGatewayToSelf: "cannot transfer a gateway to yourself",
+	ErrWifiLockNotConfigured:       "WiFi lock network is not configured, configure it and retry",
+	ErrWifiInPowerSavingMode:       "WiFi is in power saving mode, turn it off and retry",
+	ErrLockOffline:                 "lock is offline, please check and retry",
+	ErrLockBusy:                    "lock is busy, please retry later",
+	ErrGatewayNotExist:             "gateway does not exist",
+
+	// IC Card & Fingerprint
+	ErrICCardNotExist:      "IC card no longer exists",
+	ErrFingerprintNotExist: "fingerprint no longer exists",
+}
+
+var featureDescriptionsEN = map[LockFeature]string{
+	LockFeaturePasscode:                "passcode",
+	LockFeatureICCard:                  "IC card",
+	LockFeatureFingerprint:             "fingerprint",
+	LockFeatureWristband:               "Bong wristband",
+	LockFeatureAutoLock:                "auto-lock setting",
+	LockFeaturePasscodeDelete:          "passcode with delete function",
+	LockFeatureFirmwareUpgrade:         "firmware upgrade setting command",
+	LockFeaturePasscodeManagement:      "passcode management",
+	LockFeatureLockCommand:             "lock command",
+	LockFeaturePasscodeVisible:         "show or hide passcode control",
+	LockFeatureGatewayUnlock:           "gateway unlock command",
+	LockFeatureFreeze:                  "freeze and unfreeze lock",
+	LockFeatureCyclicPasscode:          "cyclic passcode",
+	LockFeatureDoorSensor:              "door sensor",
+	LockFeatureRemoteUnlockConfig:      "configurable remote unlock",
+	LockFeatureAudioManagement:         "enable or disable voice prompts",
+	LockFeatureNB:                      "NB-IoT",
+	LockFeatureAdminPasscode:           "read admin passcode",
+	LockFeatureHotelCard:               "hotel card system",
+	LockFeatureNoClockChip:             "lock has no clock chip",
+	LockFeatureNoBroadcast:             "Bluetooth does not broadcast, app tap-to-unlock unavailable",
+	LockFeaturePassageMode:             "passage mode for hours of a day",
+	LockFeatureTurnOffAutoLock:         "turn off auto-lock in passage mode",
+	LockFeatureWirelessKeypad:          "wireless keypad",
+	LockFeatureLightTime:               "lighting time setting",
+	LockFeatureHotelCardBlacklist:      "hotel card blacklist",
+	LockFeatureIdentityCard:            "identity card",
+	LockFeatureTamperAlert:             "tamper alert setting (enable/disable)",
+	LockFeatureResetButton:             "reset button setting (enable/disable)",
+	LockFeaturePrivacyLock:             "privacy lock setting (enable/disable)",
+	LockFeatureDeadLock:                "dead lock (custom mode locking both sides)",
+	LockFeaturePassageModeException:    "passage mode exceptions",
+	LockFeatureCyclicICOrFingerprint:   "cyclic fingerprint/card",
+	LockFeaturePrivacyMode:             "app-controlled privacy mode (indoor deadlock)",
+	LockFeatureLeftRightOpen:           "left/right opening direction setting",
+	LockFeatureFingerVein:              "finger vein",
+	LockFeatureTelinkBluetooth:         "Telink Bluetooth chip",
+	LockFeatureNBActivation:            "NB activation setting",
+	LockFeatureRecoverCyclicPasscode:   "cyclic passcode recovery",
+	LockFeatureWirelessKey:             "wireless key (remote control)",
+	LockFeatureAccessoryBattery:        "read accessory battery levels",
+	LockFeatureSoundVolumeLanguage:     "sound volume and language setting",
+	LockFeatureQRCode:                  "QR code",
+	LockFeatureDoorSensorState:         "door sensor state (including unknown state)",
+	LockFeaturePassageModeAutoUnlock:   "passage mode auto-unlock setting",
+	LockFeatureFingerprintDistribution: "fingerprint distribution",
+	LockFeatureZhongZhengFingerprint:   "ZhongZheng fingerprint distribution",
+	LockFeatureShengYuanFingerprint:    "ShengYuan fingerprint distribution",
+	LockFeatureWirelessDoorSensor:      "wireless door sensor",
+	LockFeatureDoorUnclosedAlarm:       "door-not-closed alarm",
+	LockFeatureProximitySensor:         "proximity sensor",
+	LockFeature3DFace:                  "3D face recognition",
+	LockFeatureAutoLockPairing:         "fully automatic lock pairing",
+	LockFeatureCPUCard:                 "CPU card",
+	LockFeatureWiFi:                    "WiFi",
+	LockFeatureWiFiStaticIP:            "WiFi lock static IP address",
+	LockFeatureIncompletePasscode:      "incomplete passcode lock",
+	LockFeatureDoubleAuth:              "double authentication",
+	LockFeatureXiongMaiVideo:           "XiongMai video intercom",
+	LockFeatureZhiAnFace:               "ZhiAn face distribution",
+	LockFeaturePalmVein:                "palm vein",
+	LockFeatureOneTimeQRCode:           "one-time QR code",
+	LockFeatureThirdPartyBluetooth:     "third-party Bluetooth devices",
+	LockFeatureWiFiPowerSave:           "WiFi power saving schedule",
+	LockFeatureMultifuncWirelessKeypad: "multifunction wireless keypad",
+	LockFeatureCustomQRCode:            "custom QR code",
+}
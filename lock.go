@@ -1,13 +1,12 @@
 package ttlock
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
+	"iter"
 	"net/http"
 	"net/url"
 	"strconv"
-	"time"
 )
 
 // Lock represents a lock object returned by the API
@@ -65,7 +64,10 @@ type LockDetail struct {
 // GetLockList retrieves the list of locks for the account.
 // lockAlias and groupId are optional filters. Pass empty string/0 to ignore.
 func (c *Client) GetLockList(pageNo, pageSize int, lockAlias string, groupId int) (*LockListResponse, error) {
-	accessToken := c.AccessToken()
+	accessToken, err := c.accessToken()
+	if err != nil {
+		return nil, err
+	}
 	endpoint := c.BaseURL + "/v3/lock/list"
 
 	params := url.Values{}
@@ -73,7 +75,7 @@ func (c *Client) GetLockList(pageNo, pageSize int, lockAlias string, groupId int
 	params.Set("accessToken", accessToken)
 	params.Set("pageNo", strconv.Itoa(pageNo))
 	params.Set("pageSize", strconv.Itoa(pageSize))
-	params.Set("date", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	params.Set("date", c.date())
 
 	if lockAlias != "" {
 		params.Set("lockAlias", lockAlias)
@@ -88,24 +90,9 @@ func (c *Client) GetLockList(pageNo, pageSize int, lockAlias string, groupId int
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
 	var listResp LockListResponse
-	if err := json.Unmarshal(body, &listResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if listResp.Errcode != 0 {
-		return nil, NewError(ErrorCode(listResp.Errcode))
+	if err := c.do(req, &listResp); err != nil {
+		return nil, err
 	}
 
 	return &listResp, nil
@@ -113,14 +100,17 @@ func (c *Client) GetLockList(pageNo, pageSize int, lockAlias string, groupId int
 
 // GetLockDetail retrieves the detailed information of a lock.
 func (c *Client) GetLockDetail(lockId int) (*LockDetail, error) {
-	accessToken := c.AccessToken()
+	accessToken, err := c.accessToken()
+	if err != nil {
+		return nil, err
+	}
 	endpoint := c.BaseURL + "/v3/lock/detail"
 
 	params := url.Values{}
 	params.Set("clientId", c.ClientID)
 	params.Set("accessToken", accessToken)
 	params.Set("lockId", strconv.Itoa(lockId))
-	params.Set("date", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	params.Set("date", c.date())
 
 	reqURL := fmt.Sprintf("%s?%s", endpoint, params.Encode())
 	req, err := http.NewRequest("GET", reqURL, nil)
@@ -128,79 +118,95 @@ func (c *Client) GetLockDetail(lockId int) (*LockDetail, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	var detail LockDetail
+	if err := c.do(req, &detail); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return &detail, nil
+}
+
+// AccessoryBattery represents the battery level of a lock accessory
+type AccessoryBattery struct {
+	AccessoryType              int    `json:"accessoryType"`              // 配件类型：1-无线键盘、2-无线钥匙（遥控）、3-无线门磁
+	AccessoryMac               string `json:"accessoryMac"`               // 配件MAC地址
+	AccessoryName              string `json:"accessoryName"`              // 配件名称
+	ElectricQuantity           int    `json:"electricQuantity"`           // 配件电量
+	ElectricQuantityUpdateDate int64  `json:"electricQuantityUpdateDate"` // 电量更新时间（毫秒时间戳）
+}
+
+// AccessoryBatteryResponse represents the response for the accessory battery API
+type AccessoryBatteryResponse struct {
+	List    []AccessoryBattery `json:"list"`
+	Errcode int                `json:"errcode"`
+	Errmsg  string             `json:"errmsg"`
+}
+
+// GetAccessoryBattery retrieves the battery levels of a lock's accessories.
+// The lock must support LockFeatureAccessoryBattery.
+func (c *Client) GetAccessoryBattery(lockId int) (*AccessoryBatteryResponse, error) {
+	accessToken, err := c.accessToken()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
+	endpoint := c.BaseURL + "/v3/lock/queryAccessoryElectricQuantity"
 
-	var detail LockDetail
-	if err := json.Unmarshal(body, &detail); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	params := url.Values{}
+	params.Set("clientId", c.ClientID)
+	params.Set("accessToken", accessToken)
+	params.Set("lockId", strconv.Itoa(lockId))
+	params.Set("date", c.date())
+
+	reqURL := fmt.Sprintf("%s?%s", endpoint, params.Encode())
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	if detail.Errcode != 0 {
-		return nil, NewError(ErrorCode(detail.Errcode))
+	var result AccessoryBatteryResponse
+	if err := c.do(req, &result); err != nil {
+		return nil, err
 	}
 
-	return &detail, nil
+	return &result, nil
 }
 
 // LockIterator allows iterating over locks without manually handling pagination
-type LockIterator struct {
-	client      *Client
-	accessToken string
-	lockAlias   string
-	groupId     int
-	pageNo      int
-	pageSize    int
-	items       []Lock
-	index       int
-	done        bool
+type LockIterator = Pager[Lock]
+
+// IterateLocks creates a new iterator for locks.
+// lockAlias and groupId are optional filters as in GetLockList.
+func (c *Client) IterateLocks(lockAlias string, groupId int) *LockIterator {
+	return IterateLocks(c, lockAlias, groupId)
 }
 
-// Next returns the next lock in the iterator.
-// It returns nil, nil when there are no more locks.
-func (it *LockIterator) Next() (*Lock, error) {
-	if it.index >= len(it.items) {
-		if it.done {
-			return nil, nil
-		}
-		it.pageNo++
-		resp, err := it.client.GetLockList(it.pageNo, it.pageSize, it.lockAlias, it.groupId)
+// IterateLocks creates a new iterator for the locks of s, like Client.IterateLocks.
+func IterateLocks(s LockService, lockAlias string, groupId int) *LockIterator {
+	return NewPager(func(pageNo, pageSize int) ([]Lock, int, error) {
+		resp, err := s.GetLockList(pageNo, pageSize, lockAlias, groupId)
 		if err != nil {
-			return nil, err
-		}
-		if len(resp.List) == 0 {
-			it.done = true
-			return nil, nil
-		}
-		it.items = resp.List
-		it.index = 0
-		if it.pageNo >= resp.Pages {
-			it.done = true
+			return nil, 0, err
 		}
-	}
+		return resp.List, resp.Pages, nil
+	}, func(l Lock) int { return l.LockID })
+}
+
+// LockFilter holds the optional filters of the lock list.
+type LockFilter struct {
+	LockAlias string
+	GroupID   int
+	PageSize  int // 0 means DefaultPageSize
 
-	item := &it.items[it.index]
-	it.index++
-	return item, nil
+	// Concurrency is the number of pages fetched in parallel; 0 or 1 fetches sequentially.
+	Concurrency int
 }
 
-// IterateLocks creates a new iterator for locks.
-func (c *Client) IterateLocks(lockAlias string, groupId int) *LockIterator {
-	accessToken := c.AccessToken()
-	return &LockIterator{
-		client:      c,
-		accessToken: accessToken,
-		lockAlias:   lockAlias,
-		groupId:     groupId,
-		pageNo:      0,
-		pageSize:    200, // Default page size
-	}
+// Locks returns a range-over-func iterator over all locks matching filter.
+func (c *Client) Locks(ctx context.Context, filter LockFilter) iter.Seq2[Lock, error] {
+	return Locks(ctx, c, filter)
+}
+
+// Locks returns a range-over-func iterator over the locks of s, like Client.Locks.
+func Locks(ctx context.Context, s LockService, filter LockFilter) iter.Seq2[Lock, error] {
+	return IterateLocks(s, filter.LockAlias, filter.GroupID).SetPageSize(filter.PageSize).SetConcurrency(filter.Concurrency).All(ctx)
 }
@@ -0,0 +1,93 @@
+package ttlock
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Region selects a TTLock cloud server
+type Region string
+
+const (
+	RegionCN Region = "cn" // 中国大陆服务器
+	RegionEU Region = "eu" // 欧洲服务器
+)
+
+// BaseURL returns the API base URL of the region.
+func (r Region) BaseURL() string {
+	if r == RegionEU {
+		return EUBaseURL
+	}
+	return CNBaseURL
+}
+
+// ParseRegion parses a region name such as "cn" or "EU".
+func ParseRegion(s string) (Region, error) {
+	switch r := Region(strings.ToLower(s)); r {
+	case RegionCN, RegionEU:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown region %q: want %s or %s", s, RegionCN, RegionEU)
+}
+
+// Option configures a Client before it logs in
+type Option func(*Client)
+
+// WithBaseURL sets the API base URL, e.g. for a proxy or a test server.
+func WithBaseURL(url string) Option {
+	return func(c *Client) {
+		c.BaseURL = url
+	}
+}
+
+// WithRegion selects the server of the given region.
+func WithRegion(r Region) Option {
+	return WithBaseURL(r.BaseURL())
+}
+
+// WithHTTPClient sets the HTTP client used for all requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.HTTPClient = hc
+	}
+}
+
+// WithPasswordMD5 logs in with a pre-hashed password (lowercase hex MD5), so
+// the plain text password need not be stored. The password argument of
+// NewClient is then ignored.
+func WithPasswordMD5(hash string) Option {
+	return func(c *Client) {
+		c.PasswordMD5 = strings.ToLower(hash)
+	}
+}
+
+// WithLocale sets the language of error messages.
+func WithLocale(locale Locale) Option {
+	return func(c *Client) {
+		c.Locale = locale
+	}
+}
+
+// WithAutoRegion makes the client retry login against the other region when
+// the configured one rejects the client or the account with ErrInvalidClient
+// or ErrInvalidUsernameOrPass. The region that accepts the login is kept.
+func WithAutoRegion() Option {
+	return func(c *Client) {
+		c.autoRegion = true
+	}
+}
+
+// loginBaseURLs returns the base URLs to try at login, in order.
+func (c *Client) loginBaseURLs() []string {
+	urls := []string{c.BaseURL}
+	if !c.autoRegion {
+		return urls
+	}
+	for _, r := range []Region{RegionCN, RegionEU} {
+		if r.BaseURL() != c.BaseURL {
+			urls = append(urls, r.BaseURL())
+		}
+	}
+	return urls
+}
@@ -0,0 +1,234 @@
+package ttlock
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"time"
+)
+
+// DefaultPageSize is the page size used by iterators unless changed with SetPageSize.
+const DefaultPageSize = 200
+
+// ErrIteratorDone is returned by Pager.Next when there are no more items.
+var ErrIteratorDone = errors.New("ttlock: no more items in iterator")
+
+// PageFunc fetches one page of a list endpoint. It returns the items of the
+// page and the total number of pages reported by the server.
+type PageFunc[T any] func(pageNo, pageSize int) (items []T, pages int, err error)
+
+// Pager iterates over a paginated list endpoint without manual page handling.
+type Pager[T any] struct {
+	fetch    PageFunc[T]
+	key      func(T) int
+	pageSize int
+	pageNo   int
+	items    []T
+	index    int
+	done     bool
+	seen     map[int]bool
+
+	concurrency int
+	pages       int // page count from the latest response
+	launched    int // highest page number requested so far
+	pending     map[int]chan pageResult[T]
+}
+
+type pageResult[T any] struct {
+	items []T
+	pages int
+	err   error
+}
+
+// NewPager creates a pager over fetch. key, if not nil, returns a stable
+// identifier used to drop items seen on an earlier page, which happens when
+// items are inserted while iterating.
+func NewPager[T any](fetch PageFunc[T], key func(T) int) *Pager[T] {
+	return &Pager[T]{
+		fetch:    fetch,
+		key:      key,
+		pageSize: DefaultPageSize,
+	}
+}
+
+// SetPageSize changes the number of items requested per page.
+// It must be called before the first item is read.
+func (p *Pager[T]) SetPageSize(n int) *Pager[T] {
+	if n > 0 {
+		p.pageSize = n
+	}
+	return p
+}
+
+// SetConcurrency enables fetching up to n pages concurrently once the first
+// response reveals the page count. Items are still yielded in order, and a
+// page rejected with ErrRateLimitExceeded is retried with backoff.
+// It must be called before the first item is read.
+func (p *Pager[T]) SetConcurrency(n int) *Pager[T] {
+	p.concurrency = n
+	return p
+}
+
+// Next returns the next item. It returns ErrIteratorDone when there are no more items.
+// A failed page fetch can be retried by calling Next again.
+func (p *Pager[T]) Next() (*T, error) {
+	return p.next(context.Background())
+}
+
+// next is Next with a context that bounds rate limit backoff and waiting for
+// prefetched pages.
+func (p *Pager[T]) next(ctx context.Context) (*T, error) {
+	for p.index >= len(p.items) {
+		if p.done {
+			return nil, ErrIteratorDone
+		}
+		if err := p.nextPage(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	item := &p.items[p.index]
+	p.index++
+	return item, nil
+}
+
+func (p *Pager[T]) nextPage(ctx context.Context) error {
+	items, pages, err := p.fetchPage(ctx, p.pageNo+1)
+	if err != nil {
+		// Drop prefetched pages so a retry starts again from the failed page.
+		p.pending = nil
+		p.launched = p.pageNo
+		return err
+	}
+	p.pageNo++
+	p.pages = pages
+	if p.launched < p.pageNo {
+		p.launched = p.pageNo
+	}
+
+	// Decide from the latest response rather than the first one, since the
+	// total may change while iterating.
+	if len(items) == 0 || p.pageNo >= pages {
+		p.done = true
+	}
+
+	if p.key != nil {
+		if p.seen == nil {
+			p.seen = make(map[int]bool)
+		}
+		fresh := items[:0]
+		for _, item := range items {
+			k := p.key(item)
+			if p.seen[k] {
+				continue
+			}
+			p.seen[k] = true
+			fresh = append(fresh, item)
+		}
+		items = fresh
+	}
+
+	p.items = items
+	p.index = 0
+	if !p.done {
+		p.prefetch(ctx)
+	}
+	return nil
+}
+
+// fetchPage returns a prefetched page if one is pending, or fetches it now.
+func (p *Pager[T]) fetchPage(ctx context.Context, pageNo int) ([]T, int, error) {
+	if ch, ok := p.pending[pageNo]; ok {
+		delete(p.pending, pageNo)
+		select {
+		case r := <-ch:
+			return r.items, r.pages, r.err
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+	if p.concurrency > 1 {
+		return p.fetchWithBackoff(ctx, pageNo)
+	}
+	return p.fetch(pageNo, p.pageSize)
+}
+
+// prefetch keeps up to concurrency pages ahead of the current one in flight.
+// The fetches give up waiting out rate limits once ctx is done.
+func (p *Pager[T]) prefetch(ctx context.Context) {
+	if p.concurrency <= 1 {
+		return
+	}
+	if p.pending == nil {
+		p.pending = make(map[int]chan pageResult[T])
+	}
+	for p.launched < p.pages && p.launched < p.pageNo+p.concurrency {
+		p.launched++
+		pageNo := p.launched
+		ch := make(chan pageResult[T], 1)
+		p.pending[pageNo] = ch
+		go func() {
+			items, pages, err := p.fetchWithBackoff(ctx, pageNo)
+			ch <- pageResult[T]{items: items, pages: pages, err: err}
+		}()
+	}
+}
+
+// fetchWithBackoff fetches a page, waiting and retrying while the server
+// reports that the call rate limit is exceeded. It stops waiting when ctx is done.
+func (p *Pager[T]) fetchWithBackoff(ctx context.Context, pageNo int) ([]T, int, error) {
+	const maxRetries = 4
+	for attempt := 0; ; attempt++ {
+		items, pages, err := p.fetch(pageNo, p.pageSize)
+		if attempt < maxRetries && IsErrorCode(err, ErrRateLimitExceeded) {
+			timer := time.NewTimer(time.Second << attempt)
+			select {
+			case <-timer.C:
+				continue
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, 0, ctx.Err()
+			}
+		}
+		return items, pages, err
+	}
+}
+
+// All returns an iterator over the remaining items for use with range.
+// Iteration stops after yielding the first error, including ctx's error; ctx also
+// cuts short rate limit backoff and prefetched page fetches.
+func (p *Pager[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			if err := ctx.Err(); err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			item, err := p.next(ctx)
+			if errors.Is(err, ErrIteratorDone) {
+				return
+			}
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(*item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Collect drains seq into a slice, stopping at the first error.
+func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var items []T
+	for item, err := range seq {
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
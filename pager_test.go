@@ -0,0 +1,138 @@
+package ttlock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/immofon/ttlock"
+	"github.com/immofon/ttlock/ttlocktest"
+)
+
+func addLocks(srv *ttlocktest.Server, n int) []int {
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = srv.AddLock(ttlocktest.Lock{Owner: "alice"})
+	}
+	return ids
+}
+
+func collectLockIDs(t *testing.T, p *ttlock.Pager[ttlock.Lock]) []int {
+	t.Helper()
+	var ids []int
+	for lock, err := range p.All(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, lock.LockID)
+	}
+	return ids
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPagerRetriesFailedPage(t *testing.T) {
+	srv, c := newTestServer(t)
+	want := addLocks(srv, 15)
+
+	p := ttlock.IterateLocks(c, "", 0).SetPageSize(10)
+	var got []int
+	for range 10 {
+		lock, err := p.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, lock.LockID)
+	}
+
+	srv.InjectError("/v3/lock/list", ttlock.ErrSystemInternalError, 1)
+	if _, err := p.Next(); !ttlock.IsErrorCode(err, ttlock.ErrSystemInternalError) {
+		t.Fatalf("got %v, want the injected error", err)
+	}
+	for {
+		lock, err := p.Next()
+		if err == ttlock.ErrIteratorDone {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, lock.LockID)
+	}
+	if !equalInts(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestPagerAllYieldsEveryPage(t *testing.T) {
+	srv, c := newTestServer(t)
+	want := addLocks(srv, 25)
+
+	got := collectLockIDs(t, ttlock.IterateLocks(c, "", 0).SetPageSize(10))
+	if !equalInts(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if n := srv.Requests("/v3/lock/list"); n != 3 {
+		t.Errorf("%d list requests, want 3", n)
+	}
+}
+
+func TestPagerPrefetchKeepsOrder(t *testing.T) {
+	srv, c := newTestServer(t)
+	want := addLocks(srv, 45)
+
+	got := collectLockIDs(t, ttlock.IterateLocks(c, "", 0).SetPageSize(10).SetConcurrency(3))
+	if !equalInts(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if n := srv.Requests("/v3/lock/list"); n != 5 {
+		t.Errorf("%d list requests, want 5", n)
+	}
+}
+
+func TestPagerBacksOffOnRateLimit(t *testing.T) {
+	srv, c := newTestServer(t)
+	want := addLocks(srv, 25)
+	srv.InjectError("/v3/lock/list", ttlock.ErrRateLimitExceeded, 1)
+
+	got := collectLockIDs(t, ttlock.IterateLocks(c, "", 0).SetPageSize(10).SetConcurrency(2))
+	if !equalInts(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if n := srv.Requests("/v3/lock/list"); n != 4 {
+		t.Errorf("%d list requests, want 4 (3 pages and 1 retry)", n)
+	}
+}
+
+func TestPagerBackoffStopsWhenCancelled(t *testing.T) {
+	srv, c := newTestServer(t)
+	addLocks(srv, 25)
+	srv.InjectError("/v3/lock/list", ttlock.ErrRateLimitExceeded, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var err error
+	for _, err = range ttlock.IterateLocks(c, "", 0).SetPageSize(10).SetConcurrency(2).All(ctx) {
+		if err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the context deadline", err)
+	}
+	if d := time.Since(start); d > 900*time.Millisecond {
+		t.Errorf("iteration took %v, backoff ignored the context", d)
+	}
+}
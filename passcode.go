@@ -1,14 +1,13 @@
 package ttlock
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
+	"iter"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
-	"time"
 )
 
 // PasscodeType represents the type of keyboard password
@@ -70,7 +69,10 @@ type PasscodeListResponse struct {
 // Note: The validity period of the passcode is precise to the hour.
 // It is recommended to pass the timestamp of the hour (e.g., 19:00:00).
 func (c *Client) GetRandomPasscode(lockID int, pwdType PasscodeType, pwdName string, startDate, endDate int64) (*RandomPasscodeResponse, error) {
-	accessToken := c.AccessToken()
+	accessToken, err := c.accessToken()
+	if err != nil {
+		return nil, err
+	}
 	endpoint := c.BaseURL + "/v3/keyboardPwd/get"
 
 	data := url.Values{}
@@ -78,7 +80,7 @@ func (c *Client) GetRandomPasscode(lockID int, pwdType PasscodeType, pwdName str
 	data.Set("accessToken", accessToken)
 	data.Set("lockId", strconv.Itoa(lockID))
 	data.Set("keyboardPwdType", strconv.Itoa(int(pwdType)))
-	data.Set("date", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	data.Set("date", c.date())
 	data.Set("startDate", strconv.FormatInt(startDate, 10))
 
 	if pwdName != "" {
@@ -95,33 +97,60 @@ func (c *Client) GetRandomPasscode(lockID int, pwdType PasscodeType, pwdName str
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	var result RandomPasscodeResponse
+	if err := c.do(req, &result); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return &result, nil
+}
+
+// PasscodeDeleteType tells DeletePasscode how the passcode is removed from the lock
+type PasscodeDeleteType int
+
+const (
+	PasscodeDeleteBluetooth PasscodeDeleteType = 1 // 已通过APP走蓝牙在锁上删除，仅删除云端记录
+	PasscodeDeleteRemote    PasscodeDeleteType = 2 // 通过网关或WiFi锁远程删除
+)
+
+// DeletePasscode deletes a passcode of a lock.
+// With PasscodeDeleteRemote the passcode is removed from the lock through its
+// gateway or WiFi; this fails with a gateway error if the lock is not online.
+// PasscodeDeleteBluetooth only deletes the cloud record, for passcodes already
+// removed from the lock over Bluetooth.
+func (c *Client) DeletePasscode(lockID, keyboardPwdID int, deleteType PasscodeDeleteType) error {
+	accessToken, err := c.accessToken()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return err
 	}
+	endpoint := c.BaseURL + "/v3/keyboardPwd/delete"
 
-	var result RandomPasscodeResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
+	data := url.Values{}
+	data.Set("clientId", c.ClientID)
+	data.Set("accessToken", accessToken)
+	data.Set("lockId", strconv.Itoa(lockID))
+	data.Set("keyboardPwdId", strconv.Itoa(keyboardPwdID))
+	data.Set("deleteType", strconv.Itoa(int(deleteType)))
+	data.Set("date", c.date())
 
-	if result.Errcode != 0 {
-		return nil, NewError(ErrorCode(result.Errcode))
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	return &result, nil
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var result apiStatus
+	return c.do(req, &result)
 }
 
 // GetPasscodeList retrieves the list of passcodes for a lock.
 // orderBy: 0-Ascending by name, 1-Descending by creation time, 2-Descending by name
 func (c *Client) GetPasscodeList(lockID, pageNo, pageSize, orderBy int, searchStr string) (*PasscodeListResponse, error) {
-	accessToken := c.AccessToken()
+	accessToken, err := c.accessToken()
+	if err != nil {
+		return nil, err
+	}
 	endpoint := c.BaseURL + "/v3/lock/listKeyboardPwd"
 
 	params := url.Values{}
@@ -131,7 +160,7 @@ func (c *Client) GetPasscodeList(lockID, pageNo, pageSize, orderBy int, searchSt
 	params.Set("pageNo", strconv.Itoa(pageNo))
 	params.Set("pageSize", strconv.Itoa(pageSize))
 	params.Set("orderBy", strconv.Itoa(orderBy))
-	params.Set("date", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	params.Set("date", c.date())
 
 	if searchStr != "" {
 		params.Set("searchStr", searchStr)
@@ -142,81 +171,50 @@ func (c *Client) GetPasscodeList(lockID, pageNo, pageSize, orderBy int, searchSt
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
 	var result PasscodeListResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if result.Errcode != 0 {
-		return nil, NewError(ErrorCode(result.Errcode))
+	if err := c.do(req, &result); err != nil {
+		return nil, err
 	}
 
 	return &result, nil
 }
 
 // PasscodeIterator allows iterating over passcodes without manually handling pagination
-type PasscodeIterator struct {
-	client      *Client
-	accessToken string
-	lockID      int
-	orderBy     int
-	searchStr   string
-	pageNo      int
-	pageSize    int
-	items       []Passcode
-	index       int
-	done        bool
+type PasscodeIterator = Pager[Passcode]
+
+// IteratePasscodes creates a new iterator for passcodes.
+// orderBy and searchStr are as in GetPasscodeList.
+func (c *Client) IteratePasscodes(lockID int, orderBy int, searchStr string) *PasscodeIterator {
+	return IteratePasscodes(c, lockID, orderBy, searchStr)
 }
 
-// Next returns the next passcode in the iterator.
-// It returns nil, nil when there are no more passcodes.
-func (it *PasscodeIterator) Next() (*Passcode, error) {
-	if it.index >= len(it.items) {
-		if it.done {
-			return nil, nil
-		}
-		it.pageNo++
-		resp, err := it.client.GetPasscodeList(it.lockID, it.pageNo, it.pageSize, it.orderBy, it.searchStr)
+// IteratePasscodes creates a new iterator for the passcodes of s, like Client.IteratePasscodes.
+func IteratePasscodes(s PasscodeService, lockID int, orderBy int, searchStr string) *PasscodeIterator {
+	return NewPager(func(pageNo, pageSize int) ([]Passcode, int, error) {
+		resp, err := s.GetPasscodeList(lockID, pageNo, pageSize, orderBy, searchStr)
 		if err != nil {
-			return nil, err
-		}
-		if len(resp.List) == 0 {
-			it.done = true
-			return nil, nil
-		}
-		it.items = resp.List
-		it.index = 0
-		if it.pageNo >= resp.Pages {
-			it.done = true
+			return nil, 0, err
 		}
-	}
+		return resp.List, resp.Pages, nil
+	}, func(p Passcode) int { return p.KeyboardPwdID })
+}
+
+// PasscodeFilter holds the optional parameters of the passcode list.
+type PasscodeFilter struct {
+	OrderBy   int
+	SearchStr string
+	PageSize  int // 0 means DefaultPageSize
 
-	item := &it.items[it.index]
-	it.index++
-	return item, nil
+	// Concurrency is the number of pages fetched in parallel; 0 or 1 fetches sequentially.
+	Concurrency int
 }
 
-// IteratePasscodes creates a new iterator for passcodes.
-func (c *Client) IteratePasscodes(lockID int, orderBy int, searchStr string) *PasscodeIterator {
-	accessToken := c.AccessToken()
-	return &PasscodeIterator{
-		client:      c,
-		accessToken: accessToken,
-		lockID:      lockID,
-		orderBy:     orderBy,
-		searchStr:   searchStr,
-		pageNo:      0,
-		pageSize:    200, // Default page size
-	}
+// Passcodes returns a range-over-func iterator over all passcodes of a lock.
+func (c *Client) Passcodes(ctx context.Context, lockID int, filter PasscodeFilter) iter.Seq2[Passcode, error] {
+	return Passcodes(ctx, c, lockID, filter)
+}
+
+// Passcodes returns a range-over-func iterator over the passcodes of s, like Client.Passcodes.
+func Passcodes(ctx context.Context, s PasscodeService, lockID int, filter PasscodeFilter) iter.Seq2[Passcode, error] {
+	return IteratePasscodes(s, lockID, filter.OrderBy, filter.SearchStr).SetPageSize(filter.PageSize).SetConcurrency(filter.Concurrency).All(ctx)
 }
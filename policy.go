@@ -0,0 +1,495 @@
+package ttlock
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy describes the eKeys and passcodes that should exist on locks.
+// Keys and passcodes are identified by their names, so renaming one in the
+// policy replaces it.
+type Policy struct {
+	Locks []LockPolicy `toml:"locks" json:"locks"`
+}
+
+// LockPolicy lists the desired eKeys and passcodes of one lock.
+type LockPolicy struct {
+	Lock string `toml:"lock" json:"lock"` // lock alias, or lock ID
+
+	// Prune deletes eKeys and passcodes of the lock that the policy does not
+	// list, including unnamed ones. Without it they are left alone.
+	Prune bool `toml:"prune" json:"prune"`
+
+	Keys      []KeyPolicy      `toml:"keys" json:"keys"`
+	Passcodes []PasscodePolicy `toml:"passcodes" json:"passcodes"`
+}
+
+// KeyPolicy is a desired eKey.
+type KeyPolicy struct {
+	Name     string `toml:"name" json:"name"`         // key name; identifies the key
+	Username string `toml:"username" json:"username"` // receiver
+
+	// Start and End are wall-clock times at the lock. Leave both zero for a
+	// permanent key.
+	Start time.Time `toml:"start" json:"start"`
+	End   time.Time `toml:"end" json:"end"`
+
+	KeyRight     bool   `toml:"key_right" json:"keyRight"`         // authorized admin
+	RemoteEnable bool   `toml:"remote_enable" json:"remoteEnable"` // allow remote unlock
+	Remarks      string `toml:"remarks" json:"remarks"`            // sent with new keys only
+}
+
+// PasscodePolicy is a desired random passcode.
+type PasscodePolicy struct {
+	Name string `toml:"name" json:"name"` // passcode name; identifies the passcode
+
+	// Start and End are wall-clock times at the lock, rounded to the hour as
+	// in PlanPasscodeWindow. A zero End gives a permanent passcode.
+	Start time.Time `toml:"start" json:"start"`
+	End   time.Time `toml:"end" json:"end"`
+
+	// Cyclic is an optional schedule such as "Mon-Fri 08-18" (see
+	// ParseCyclicSchedule); Start and End then give its first and last date.
+	Cyclic string `toml:"cyclic" json:"cyclic"`
+}
+
+// ChangeAction is what a Change does
+type ChangeAction string
+
+const (
+	ChangeCreate  ChangeAction = "create"
+	ChangeUpdate  ChangeAction = "update"  // changed in place
+	ChangeReplace ChangeAction = "replace" // deleted and created again
+	ChangeDelete  ChangeAction = "delete"
+)
+
+// Change is one step of a Plan.
+type Change struct {
+	Action  ChangeAction `json:"action"`
+	Kind    string       `json:"kind"` // "key" or "passcode"
+	LockID  int          `json:"lockId"`
+	Lock    string       `json:"lock"` // the lock as named in the policy
+	Name    string       `json:"name"`
+	Details []string     `json:"details,omitempty"` // what is created or differs
+
+	id       int // keyId or keyboardPwdId of the existing item
+	key      *desiredKey
+	passcode *desiredPasscode
+}
+
+// Plan is the list of changes that makes the cloud match a Policy.
+type Plan struct {
+	Changes []Change `json:"changes"`
+}
+
+type desiredKey struct {
+	name         string
+	username     string
+	startDate    int64
+	endDate      int64
+	keyRight     int
+	remoteEnable int
+	remarks      string
+}
+
+type desiredPasscode struct {
+	name      string
+	pwdType   PasscodeType
+	startDate int64
+	endDate   int64
+}
+
+// PlanPolicy compares policy with the eKeys and passcodes of its locks and
+// returns the changes needed to make them match. Nothing is modified.
+func (c *Client) PlanPolicy(ctx context.Context, policy *Policy) (*Plan, error) {
+	return PlanPolicy(ctx, c, policy)
+}
+
+// PlanPolicy plans policy against s, like Client.PlanPolicy.
+func PlanPolicy(ctx context.Context, s Service, policy *Policy) (*Plan, error) {
+	ids, err := resolvePolicyLocks(ctx, s, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	for i, lp := range policy.Locks {
+		changes, err := planLock(ctx, s, lp, ids[i])
+		if err != nil {
+			return nil, fmt.Errorf("lock %q: %w", lp.Lock, err)
+		}
+		plan.Changes = append(plan.Changes, changes...)
+	}
+	return plan, nil
+}
+
+// resolvePolicyLocks returns the lock ID of every LockPolicy, looking up
+// aliases in the lock list
+func resolvePolicyLocks(ctx context.Context, s LockService, policy *Policy) ([]int, error) {
+	ids := make([]int, len(policy.Locks))
+	var aliases map[string][]int
+	seen := make(map[int]string)
+
+	for i, lp := range policy.Locks {
+		if id, err := strconv.Atoi(lp.Lock); err == nil {
+			ids[i] = id
+		} else {
+			if aliases == nil {
+				aliases = make(map[string][]int)
+				for lock, err := range Locks(ctx, s, LockFilter{}) {
+					if err != nil {
+						return nil, err
+					}
+					aliases[lock.LockAlias] = append(aliases[lock.LockAlias], lock.LockID)
+				}
+			}
+			switch matches := aliases[lp.Lock]; len(matches) {
+			case 0:
+				return nil, fmt.Errorf("lock %q: no lock has this alias", lp.Lock)
+			case 1:
+				ids[i] = matches[0]
+			default:
+				return nil, fmt.Errorf("lock %q: alias matches %d locks; use the lock ID", lp.Lock, len(matches))
+			}
+		}
+		if other, ok := seen[ids[i]]; ok {
+			return nil, fmt.Errorf("lock %q: same lock as %q", lp.Lock, other)
+		}
+		seen[ids[i]] = lp.Lock
+	}
+	return ids, nil
+}
+
+// inactiveKeyStatuses are eKey states that no longer grant access
+var inactiveKeyStatuses = map[string]bool{
+	"110408": true, // 已删除
+	"110410": true, // 已重置
+}
+
+// planLock plans the changes of one lock
+func planLock(ctx context.Context, s Service, lp LockPolicy, lockID int) ([]Change, error) {
+	detail, err := s.GetLockDetail(lockID)
+	if err != nil {
+		return nil, err
+	}
+	loc := detail.Location()
+
+	keys, err := desiredKeys(lp.Keys, loc)
+	if err != nil {
+		return nil, err
+	}
+	passcodes, err := desiredPasscodes(lp.Passcodes, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	change := func(action ChangeAction, kind, name string, details ...string) Change {
+		return Change{Action: action, Kind: kind, LockID: lockID, Lock: lp.Lock, Name: name, Details: details}
+	}
+	var changes []Change
+
+	// eKeys
+	matched := make(map[string]bool)
+	for k, err := range Keys(ctx, s, lockID, KeyFilter{}) {
+		if err != nil {
+			return nil, err
+		}
+		if inactiveKeyStatuses[k.KeyStatus] {
+			continue
+		}
+		want, ok := keys[k.KeyName]
+		if !ok || matched[k.KeyName] {
+			// Unlisted keys are deleted when pruning; duplicates of a listed name always
+			if ok || lp.Prune {
+				ch := change(ChangeDelete, "key", k.KeyName, "username: "+k.Username)
+				ch.id = k.KeyID
+				changes = append(changes, ch)
+			}
+			continue
+		}
+		matched[k.KeyName] = true
+
+		if diff := keyReplaceDiff(k, want); len(diff) > 0 {
+			ch := change(ChangeReplace, "key", k.KeyName, diff...)
+			ch.id, ch.key = k.KeyID, want
+			changes = append(changes, ch)
+		} else if k.StartDate != want.startDate || k.EndDate != want.endDate {
+			ch := change(ChangeUpdate, "key", k.KeyName, fmt.Sprintf("period: %s -> %s",
+				formatPeriod(k.StartDate, k.EndDate, loc), formatPeriod(want.startDate, want.endDate, loc)))
+			ch.id, ch.key = k.KeyID, want
+			changes = append(changes, ch)
+		}
+	}
+	for _, kp := range lp.Keys {
+		if want := keys[kp.Name]; !matched[kp.Name] {
+			ch := change(ChangeCreate, "key", kp.Name, "username: "+want.username,
+				"period: "+formatPeriod(want.startDate, want.endDate, loc))
+			ch.key = want
+			changes = append(changes, ch)
+		}
+	}
+
+	// Passcodes
+	matched = make(map[string]bool)
+	for p, err := range Passcodes(ctx, s, lockID, PasscodeFilter{}) {
+		if err != nil {
+			return nil, err
+		}
+		want, ok := passcodes[p.KeyboardPwdName]
+		if !ok || matched[p.KeyboardPwdName] {
+			if ok || lp.Prune {
+				ch := change(ChangeDelete, "passcode", p.KeyboardPwdName, "type: "+passcodeTypeName(PasscodeType(p.KeyboardPwdType)))
+				ch.id = p.KeyboardPwdID
+				changes = append(changes, ch)
+			}
+			continue
+		}
+		matched[p.KeyboardPwdName] = true
+
+		var diff []string
+		if have := PasscodeType(p.KeyboardPwdType); have != want.pwdType {
+			diff = append(diff, fmt.Sprintf("type: %s -> %s", passcodeTypeName(have), passcodeTypeName(want.pwdType)))
+		}
+		if p.StartDate != want.startDate || p.EndDate != want.endDate {
+			diff = append(diff, fmt.Sprintf("period: %s -> %s",
+				formatPeriod(p.StartDate, p.EndDate, loc), formatPeriod(want.startDate, want.endDate, loc)))
+		}
+		if len(diff) > 0 {
+			ch := change(ChangeReplace, "passcode", p.KeyboardPwdName, diff...)
+			ch.id, ch.passcode = p.KeyboardPwdID, want
+			changes = append(changes, ch)
+		}
+	}
+	for _, pp := range lp.Passcodes {
+		if want := passcodes[pp.Name]; !matched[pp.Name] {
+			ch := change(ChangeCreate, "passcode", pp.Name, "type: "+passcodeTypeName(want.pwdType),
+				"period: "+formatPeriod(want.startDate, want.endDate, loc))
+			ch.passcode = want
+			changes = append(changes, ch)
+		}
+	}
+
+	return changes, nil
+}
+
+// keyReplaceDiff lists the differences of k from want that cannot be changed in place
+func keyReplaceDiff(k Key, want *desiredKey) []string {
+	var diff []string
+	if k.Username != want.username {
+		diff = append(diff, fmt.Sprintf("username: %s -> %s", k.Username, want.username))
+	}
+	if k.KeyRight != want.keyRight {
+		diff = append(diff, fmt.Sprintf("key_right: %d -> %d", k.KeyRight, want.keyRight))
+	}
+	// 0 means the server did not report the setting
+	if k.RemoteEnable != 0 && k.RemoteEnable != want.remoteEnable {
+		diff = append(diff, fmt.Sprintf("remote_enable: %d -> %d", k.RemoteEnable, want.remoteEnable))
+	}
+	return diff
+}
+
+// desiredKeys converts the key policies of a lock, keyed by name
+func desiredKeys(policies []KeyPolicy, loc *time.Location) (map[string]*desiredKey, error) {
+	keys := make(map[string]*desiredKey)
+	for _, kp := range policies {
+		if kp.Name == "" || kp.Username == "" {
+			return nil, fmt.Errorf("every key needs a name and a username")
+		}
+		if _, ok := keys[kp.Name]; ok {
+			return nil, fmt.Errorf("key %q is listed twice", kp.Name)
+		}
+
+		want := &desiredKey{name: kp.Name, username: kp.Username, remoteEnable: 2, remarks: kp.Remarks}
+		if kp.KeyRight {
+			want.keyRight = 1
+		}
+		if kp.RemoteEnable {
+			want.remoteEnable = 1
+		}
+		switch {
+		case kp.Start.IsZero() && kp.End.IsZero():
+		case kp.Start.IsZero() || kp.End.IsZero():
+			return nil, fmt.Errorf("key %q: set both start and end, or neither for a permanent key", kp.Name)
+		default:
+			want.startDate = inLocation(kp.Start, loc).UnixMilli()
+			want.endDate = inLocation(kp.End, loc).UnixMilli()
+			if want.endDate <= want.startDate {
+				return nil, fmt.Errorf("key %q: end is not after start", kp.Name)
+			}
+		}
+		keys[kp.Name] = want
+	}
+	return keys, nil
+}
+
+// desiredPasscodes converts the passcode policies of a lock, keyed by name
+func desiredPasscodes(policies []PasscodePolicy, loc *time.Location) (map[string]*desiredPasscode, error) {
+	passcodes := make(map[string]*desiredPasscode)
+	for _, pp := range policies {
+		if pp.Name == "" {
+			return nil, fmt.Errorf("every passcode needs a name")
+		}
+		if _, ok := passcodes[pp.Name]; ok {
+			return nil, fmt.Errorf("passcode %q is listed twice", pp.Name)
+		}
+
+		want := &desiredPasscode{name: pp.Name}
+		if pp.Cyclic != "" {
+			schedule, err := ParseCyclicSchedule(pp.Cyclic)
+			if err != nil {
+				return nil, fmt.Errorf("passcode %q: %w", pp.Name, err)
+			}
+			if want.pwdType, err = schedule.PasscodeType(); err != nil {
+				return nil, fmt.Errorf("passcode %q: %w", pp.Name, err)
+			}
+			if want.startDate, want.endDate, err = schedule.Dates(loc, pp.Start, pp.End); err != nil {
+				return nil, fmt.Errorf("passcode %q: %w", pp.Name, err)
+			}
+		} else {
+			w, err := PlanPasscodeWindow(loc, pp.Start, pp.End, false)
+			if err != nil {
+				return nil, fmt.Errorf("passcode %q: %w", pp.Name, err)
+			}
+			want.pwdType, want.startDate = w.Type, w.Start.UnixMilli()
+			if !w.End.IsZero() {
+				want.endDate = w.End.UnixMilli()
+			}
+		}
+		passcodes[pp.Name] = want
+	}
+	return passcodes, nil
+}
+
+// ApplyPlan carries out the changes of plan in order. It stops at the first
+// failure and returns the number of changes applied.
+func (c *Client) ApplyPlan(plan *Plan) (int, error) {
+	return ApplyPlan(c, plan)
+}
+
+// ApplyPlan carries out plan through s, like Client.ApplyPlan.
+func ApplyPlan(s Service, plan *Plan) (int, error) {
+	for i, ch := range plan.Changes {
+		if err := applyChange(s, ch); err != nil {
+			return i, fmt.Errorf("%s %s %q on %q: %w", ch.Action, ch.Kind, ch.Name, ch.Lock, err)
+		}
+	}
+	return len(plan.Changes), nil
+}
+
+func applyChange(s Service, ch Change) error {
+	if ch.Action == ChangeDelete || ch.Action == ChangeReplace {
+		var err error
+		if ch.Kind == "key" {
+			err = s.DeleteKey(ch.id)
+		} else {
+			err = deletePasscode(s, ch.LockID, ch.id)
+		}
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+
+	switch {
+	case ch.Action == ChangeDelete:
+		return nil
+	case ch.Action == ChangeUpdate:
+		return s.ChangeKeyPeriod(ch.id, ch.key.startDate, ch.key.endDate)
+	case ch.Kind == "key":
+		k := ch.key
+		_, err := s.SendKey(ch.LockID, k.username, k.name, k.startDate, k.endDate, &SendKeyOptions{
+			Remarks:      k.remarks,
+			RemoteEnable: k.remoteEnable,
+			KeyRight:     k.keyRight,
+		})
+		return err
+	default:
+		p := ch.passcode
+		_, err := s.GetRandomPasscode(ch.LockID, p.pwdType, p.name, p.startDate, p.endDate)
+		return err
+	}
+}
+
+// deletePasscode removes a passcode through the lock's gateway, falling back
+// to deleting the cloud record when the lock cannot be reached
+func deletePasscode(s PasscodeService, lockID, keyboardPwdID int) error {
+	err := s.DeletePasscode(lockID, keyboardPwdID, PasscodeDeleteRemote)
+	if IsGatewayError(err) {
+		err = s.DeletePasscode(lockID, keyboardPwdID, PasscodeDeleteBluetooth)
+	}
+	return err
+}
+
+// Empty reports whether the plan has no changes.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+var changeSymbols = map[ChangeAction]string{
+	ChangeCreate:  "+",
+	ChangeUpdate:  "~",
+	ChangeReplace: "-/+",
+	ChangeDelete:  "-",
+}
+
+// String formats the plan like "terraform plan".
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "No changes. The locks match the policy.\n"
+	}
+
+	var b strings.Builder
+	var add, change, destroy int
+	for _, ch := range p.Changes {
+		fmt.Fprintf(&b, "%3s %s %q on %s (%d)\n", changeSymbols[ch.Action], ch.Kind, ch.Name, ch.Lock, ch.LockID)
+		for _, d := range ch.Details {
+			fmt.Fprintf(&b, "      %s\n", d)
+		}
+		switch ch.Action {
+		case ChangeCreate:
+			add++
+		case ChangeUpdate:
+			change++
+		case ChangeReplace:
+			add++
+			destroy++
+		case ChangeDelete:
+			destroy++
+		}
+	}
+	fmt.Fprintf(&b, "\nPlan: %d to add, %d to change, %d to destroy.\n", add, change, destroy)
+	return b.String()
+}
+
+// formatPeriod formats a validity period in the lock's time zone
+func formatPeriod(startDate, endDate int64, loc *time.Location) string {
+	const layout = "2006-01-02 15:04"
+	if startDate == 0 && endDate == 0 {
+		return "permanent"
+	}
+	start := time.UnixMilli(startDate).In(loc).Format(layout)
+	if endDate == 0 {
+		return "from " + start
+	}
+	return start + " - " + time.UnixMilli(endDate).In(loc).Format(layout)
+}
+
+// passcodeTypeName returns a short name of a passcode type
+func passcodeTypeName(t PasscodeType) string {
+	switch t {
+	case PasscodeTypeOneTime:
+		return "one-time"
+	case PasscodeTypePermanent:
+		return "permanent"
+	case PasscodeTypePeriod:
+		return "period"
+	case PasscodeTypeDelete:
+		return "delete"
+	}
+	if _, ok := cyclicPasscodeDays[t]; ok {
+		return "cyclic " + t.cyclicString()
+	}
+	return fmt.Sprintf("type %d", int(t))
+}
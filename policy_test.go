@@ -0,0 +1,128 @@
+package ttlock_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/immofon/ttlock"
+)
+
+func wallClock(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func planActions(plan *ttlock.Plan) map[string]ttlock.ChangeAction {
+	actions := make(map[string]ttlock.ChangeAction)
+	for _, ch := range plan.Changes {
+		actions[ch.Kind+" "+ch.Name] = ch.Action
+	}
+	return actions
+}
+
+func TestPolicyPlanAndApply(t *testing.T) {
+	srv, c := newTestServer(t)
+	srv.AddUser("bob", "pw")
+	srv.AddUser("carol", "pw")
+	lockID := srv.AddLock(passcodeLock("Front"))
+	srv.AddKey(ttlock.Key{LockID: lockID, Username: "dave", KeyName: "old", KeyStatus: "110401"})
+	ctx := context.Background()
+
+	policy := &ttlock.Policy{Locks: []ttlock.LockPolicy{{
+		Lock: "Front",
+		Keys: []ttlock.KeyPolicy{
+			{Name: "bob", Username: "bob", Start: wallClock("2030-11-01 10:00"), End: wallClock("2030-11-05 10:00")},
+			{Name: "carol", Username: "carol"},
+		},
+		Passcodes: []ttlock.PasscodePolicy{
+			{Name: "cleaner", Start: wallClock("2030-11-01 00:00"), End: wallClock("2030-12-01 00:00"), Cyclic: "Mon-Fri 08-18"},
+			{Name: "guest", Start: wallClock("2030-11-01 15:00"), End: wallClock("2030-11-03 11:00")},
+		},
+	}}}
+
+	plan, err := c.PlanPolicy(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without prune the unlisted key "old" is left alone
+	if got := planActions(plan); len(got) != 4 || got["key bob"] != ttlock.ChangeCreate || got["passcode cleaner"] != ttlock.ChangeCreate {
+		t.Fatalf("plan %v", got)
+	}
+	if !strings.Contains(plan.String(), "Plan: 4 to add, 0 to change, 0 to destroy.") {
+		t.Errorf("plan summary:\n%s", plan)
+	}
+	if n, err := c.ApplyPlan(plan); err != nil || n != 4 {
+		t.Fatalf("applied %d: %v", n, err)
+	}
+
+	plan, err = c.PlanPolicy(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Fatalf("plan after apply is not empty:\n%s", plan)
+	}
+
+	// A new period is changed in place, a new receiver replaces the key
+	policy.Locks[0].Keys[0].End = wallClock("2030-11-06 10:00")
+	policy.Locks[0].Keys[1].Username = "bob"
+	policy.Locks[0].Passcodes = policy.Locks[0].Passcodes[:1]
+	policy.Locks[0].Prune = true
+	plan, err = c.PlanPolicy(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]ttlock.ChangeAction{
+		"key old":        ttlock.ChangeDelete,
+		"key bob":        ttlock.ChangeUpdate,
+		"key carol":      ttlock.ChangeReplace,
+		"passcode guest": ttlock.ChangeDelete,
+	}
+	got := planActions(plan)
+	for name, action := range want {
+		if got[name] != action {
+			t.Errorf("%s: %q, want %q", name, got[name], action)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("plan %v", got)
+	}
+	if _, err := c.ApplyPlan(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	if plan, err = c.PlanPolicy(ctx, policy); err != nil || !plan.Empty() {
+		t.Fatalf("plan after second apply: %v\n%s", err, plan)
+	}
+	if len(srv.Keys(lockID)) != 2 || len(srv.Passcodes(lockID)) != 1 {
+		t.Errorf("%d keys and %d passcodes on the server", len(srv.Keys(lockID)), len(srv.Passcodes(lockID)))
+	}
+}
+
+func TestPolicyErrors(t *testing.T) {
+	srv, c := newTestServer(t)
+	srv.AddLock(passcodeLock("Twin"))
+	srv.AddLock(passcodeLock("Twin"))
+	srv.AddLock(passcodeLock("Front"))
+	ctx := context.Background()
+
+	for name, policy := range map[string]ttlock.Policy{
+		"unknown alias":   {Locks: []ttlock.LockPolicy{{Lock: "Back"}}},
+		"ambiguous alias": {Locks: []ttlock.LockPolicy{{Lock: "Twin"}}},
+		"duplicate key": {Locks: []ttlock.LockPolicy{{Lock: "Front", Keys: []ttlock.KeyPolicy{
+			{Name: "a", Username: "bob"}, {Name: "a", Username: "carol"},
+		}}}},
+		"half a period": {Locks: []ttlock.LockPolicy{{Lock: "Front", Keys: []ttlock.KeyPolicy{
+			{Name: "a", Username: "bob", Start: wallClock("2030-11-01 10:00")},
+		}}}},
+	} {
+		if _, err := c.PlanPolicy(ctx, &policy); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
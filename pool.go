@@ -0,0 +1,228 @@
+package ttlock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Account holds the credentials of one TTLock account in a ClientPool
+type Account struct {
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string // Plain text password; will be MD5 hashed automatically
+	Options      []Option
+}
+
+// DefaultIndexRefreshInterval is how often ClientForLock may rebuild the
+// lock index of a ClientPool for a lock it does not know.
+const DefaultIndexRefreshInterval = time.Minute
+
+// ClientPool manages clients for several TTLock accounts keyed by name.
+// Clients are created on first use and share one HTTP client, so they share
+// connections and the optional rate limiter.
+type ClientPool struct {
+	httpClient *http.Client
+
+	refreshMu       sync.Mutex // serializes index refreshes by ClientForLock
+	refreshInterval time.Duration
+
+	mu        sync.Mutex
+	locale    Locale // language of the pool's own errors
+	accounts  map[string]Account
+	clients   map[string]*Client
+	logins    map[string]*poolLogin // logins in progress by account name
+	lockIndex map[int]string        // lock ID -> account name
+	indexedAt time.Time             // when lockIndex was built
+}
+
+// poolLogin is a login in progress; done is closed once c or err is set
+type poolLogin struct {
+	done chan struct{}
+	c    *Client
+	err  error
+}
+
+// NewClientPool creates an empty pool. If limiter is not nil, requests of all
+// accounts together are limited by it.
+func NewClientPool(limiter *RateLimiter) *ClientPool {
+	var transport http.RoundTripper = http.DefaultTransport.(*http.Transport).Clone()
+	if limiter != nil {
+		transport = limiter.Transport(transport)
+	}
+	return &ClientPool{
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+		},
+		accounts: make(map[string]Account),
+		clients:  make(map[string]*Client),
+		logins:   make(map[string]*poolLogin),
+
+		refreshInterval: DefaultIndexRefreshInterval,
+	}
+}
+
+// SetRefreshInterval sets how long ClientForLock waits after an index
+// refresh before it rebuilds the index again for an unknown lock.
+// Until then unknown locks fail with ErrLockNotExist without calling the API.
+func (p *ClientPool) SetRefreshInterval(d time.Duration) {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	p.refreshInterval = d
+}
+
+// SetLocale sets the language of the errors created by the pool itself, such
+// as ErrLockNotExist from ClientForLock. The clients of the accounts use the
+// locale set in their Options.
+func (p *ClientPool) SetLocale(locale Locale) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.locale = locale
+}
+
+// errorLocale implements localized
+func (p *ClientPool) errorLocale() Locale {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.locale
+}
+
+// Add registers an account under name, replacing any account of that name.
+func (p *ClientPool) Add(name string, account Account) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.accounts[name] = account
+	delete(p.clients, name)
+	delete(p.logins, name)
+	for id, owner := range p.lockIndex {
+		if owner == name {
+			delete(p.lockIndex, id)
+		}
+	}
+	// The new account's locks are unknown until the next refresh
+	p.indexedAt = time.Time{}
+}
+
+// Names returns the registered account names in sorted order.
+func (p *ClientPool) Names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.accounts))
+	for name := range p.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Client returns the client of the named account, logging in on first use.
+// Concurrent callers for the same account share one login, and logins of
+// different accounts do not wait for each other.
+func (p *ClientPool) Client(name string) (*Client, error) {
+	p.mu.Lock()
+	if c, ok := p.clients[name]; ok {
+		p.mu.Unlock()
+		return c, nil
+	}
+	if login, ok := p.logins[name]; ok {
+		p.mu.Unlock()
+		<-login.done
+		return login.c, login.err
+	}
+	account, ok := p.accounts[name]
+	if !ok {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("unknown account %q", name)
+	}
+	login := &poolLogin{done: make(chan struct{})}
+	p.logins[name] = login
+	p.mu.Unlock()
+
+	opts := append(append([]Option(nil), account.Options...), WithHTTPClient(p.httpClient))
+	login.c, login.err = Login(account.ClientID, account.ClientSecret, account.Username, account.Password, opts...)
+	if login.err != nil {
+		login.c, login.err = nil, fmt.Errorf("account %q: %w", name, login.err)
+	}
+
+	p.mu.Lock()
+	// Unless Add replaced the account meanwhile
+	if p.logins[name] == login {
+		delete(p.logins, name)
+		if login.err == nil {
+			p.clients[name] = login.c
+		}
+	}
+	p.mu.Unlock()
+	close(login.done)
+	return login.c, login.err
+}
+
+// RefreshIndex rebuilds the lock ID index by listing the locks of every account.
+func (p *ClientPool) RefreshIndex(ctx context.Context) error {
+	index := make(map[int]string)
+	for _, name := range p.Names() {
+		c, err := p.Client(name)
+		if err != nil {
+			return err
+		}
+		for lock, err := range c.Locks(ctx, LockFilter{}) {
+			if err != nil {
+				return fmt.Errorf("account %q: %w", name, err)
+			}
+			index[lock.LockID] = name
+		}
+	}
+
+	p.mu.Lock()
+	p.lockIndex = index
+	p.indexedAt = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// ClientForLock returns the client of the account owning lockID and the
+// account's name. The index is built on first use and rebuilt when the lock
+// is not found, at most once per refresh interval (see SetRefreshInterval);
+// if the lock is still missing, ErrLockNotExist is returned.
+func (p *ClientPool) ClientForLock(ctx context.Context, lockID int) (*Client, string, error) {
+	if c, name, ok, err := p.lookupLock(lockID); ok || err != nil {
+		return c, name, err
+	}
+
+	// Concurrent misses wait for one refresh instead of each starting one
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	if c, name, ok, err := p.lookupLock(lockID); ok || err != nil {
+		return c, name, err
+	}
+	p.mu.Lock()
+	fresh := !p.indexedAt.IsZero() && time.Since(p.indexedAt) < p.refreshInterval
+	p.mu.Unlock()
+	if fresh {
+		return nil, "", serviceError(p, ErrLockNotExist)
+	}
+
+	if err := p.RefreshIndex(ctx); err != nil {
+		return nil, "", err
+	}
+	if c, name, ok, err := p.lookupLock(lockID); ok || err != nil {
+		return c, name, err
+	}
+	return nil, "", serviceError(p, ErrLockNotExist)
+}
+
+func (p *ClientPool) lookupLock(lockID int) (*Client, string, bool, error) {
+	p.mu.Lock()
+	name, ok := p.lockIndex[lockID]
+	p.mu.Unlock()
+	if !ok {
+		return nil, "", false, nil
+	}
+	c, err := p.Client(name)
+	return c, name, true, err
+}
@@ -0,0 +1,104 @@
+package ttlock_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/immofon/ttlock"
+	"github.com/immofon/ttlock/ttlocktest"
+)
+
+func newTestPool(t *testing.T) (*ttlocktest.Server, *ttlock.ClientPool) {
+	t.Helper()
+	srv := ttlocktest.NewServer()
+	t.Cleanup(srv.Close)
+	pool := ttlock.NewClientPool(nil)
+	for _, name := range []string{"alice", "bob"} {
+		srv.AddUser(name, "secret")
+		pool.Add(name, ttlock.Account{
+			ClientID:     srv.ClientID,
+			ClientSecret: srv.ClientSecret,
+			Username:     name,
+			Password:     "secret",
+			Options:      []ttlock.Option{ttlock.WithBaseURL(srv.URL)},
+		})
+	}
+	return srv, pool
+}
+
+func TestClientPoolLogsInOnce(t *testing.T) {
+	srv, pool := newTestPool(t)
+
+	var wg sync.WaitGroup
+	clients := make([]*ttlock.Client, 8)
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := []string{"alice", "bob"}[i%2]
+			c, err := pool.Client(name)
+			if err != nil {
+				t.Error(err)
+			}
+			clients[i] = c
+		}()
+	}
+	wg.Wait()
+
+	if n := srv.Requests("/oauth2/token"); n != 2 {
+		t.Errorf("%d logins, want one per account", n)
+	}
+	for i := 2; i < len(clients); i++ {
+		if clients[i] != clients[i%2] {
+			t.Errorf("caller %d got a different client", i)
+		}
+	}
+	if _, err := pool.Client("carol"); err == nil {
+		t.Error("no error for an unknown account")
+	}
+}
+
+func TestClientForLockLimitsRefreshes(t *testing.T) {
+	srv, pool := newTestPool(t)
+	ctx := context.Background()
+	lockID := srv.AddLock(ttlocktest.Lock{Owner: "bob", Detail: ttlock.LockDetail{LockAlias: "Front"}})
+
+	c, name, err := pool.ClientForLock(ctx, lockID)
+	if err != nil || c == nil || name != "bob" {
+		t.Fatalf("got %v %q, %v", c, name, err)
+	}
+	lists := srv.Requests("/v3/lock/list")
+
+	// Unknown locks do not rebuild the index again within the interval
+	for range 3 {
+		if _, _, err := pool.ClientForLock(ctx, 99); !ttlock.IsErrorCode(err, ttlock.ErrLockNotExist) {
+			t.Fatalf("got %v, want ErrLockNotExist", err)
+		}
+	}
+	if n := srv.Requests("/v3/lock/list"); n != lists {
+		t.Errorf("%d lock lists for unknown locks within the interval", n-lists)
+	}
+
+	pool.SetRefreshInterval(0)
+	newID := srv.AddLock(ttlocktest.Lock{Owner: "alice", Detail: ttlock.LockDetail{LockAlias: "Back"}})
+	if _, name, err := pool.ClientForLock(ctx, newID); err != nil || name != "alice" {
+		t.Errorf("new lock: %q, %v", name, err)
+	}
+}
+
+func TestClientForLockErrorLocale(t *testing.T) {
+	_, pool := newTestPool(t)
+	ctx := context.Background()
+
+	_, _, err := pool.ClientForLock(ctx, 99)
+	if e, ok := err.(*ttlock.Error); !ok || e.Message != ttlock.NewLocalizedError(ttlock.ErrLockNotExist, ttlock.LocaleZhCN).Message {
+		t.Errorf("default locale: %v", err)
+	}
+
+	pool.SetLocale(ttlock.LocaleEN)
+	_, _, err = pool.ClientForLock(ctx, 99)
+	if e, ok := err.(*ttlock.Error); !ok || e.Message != ttlock.NewLocalizedError(ttlock.ErrLockNotExist, ttlock.LocaleEN).Message {
+		t.Errorf("English: %v", err)
+	}
+}
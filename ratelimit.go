@@ -0,0 +1,75 @@
+package ttlock
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces requests evenly so that at most a given number are
+// started per second. It is safe for concurrent use.
+type RateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perSecond requests per second.
+// A perSecond of zero or less (or NaN) means no limit.
+func NewRateLimiter(perSecond float64) *RateLimiter {
+	if !(perSecond > 0) {
+		return &RateLimiter{}
+	}
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until the next request may start or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	slot := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Transport wraps base so that every request waits for the limiter first.
+// A nil base means http.DefaultTransport.
+func (l *RateLimiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitedTransport{limiter: l, base: base}
+}
+
+type rateLimitedTransport struct {
+	limiter *RateLimiter
+	base    http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
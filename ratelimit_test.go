@@ -0,0 +1,32 @@
+package ttlock
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWithoutLimit(t *testing.T) {
+	for _, perSecond := range []float64{0, -1, math.NaN()} {
+		l := NewRateLimiter(perSecond)
+		start := time.Now()
+		for range 100 {
+			if err := l.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if d := time.Since(start); d > 100*time.Millisecond {
+			t.Errorf("perSecond %v: 100 waits took %v", perSecond, d)
+		}
+	}
+
+	l := NewRateLimiter(20)
+	start := time.Now()
+	for range 3 {
+		l.Wait(context.Background())
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("3 requests at 20/s took only %v", d)
+	}
+}
@@ -0,0 +1,142 @@
+package ttlock
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Location returns a fixed time zone matching the lock's TimezoneRawOffset.
+// The raw offset carries no daylight saving information, so the zone is fixed.
+func (l *LockDetail) Location() *time.Location {
+	offset := int(l.TimezoneRawOffset / 1000)
+	sign := "+"
+	abs := offset
+	if abs < 0 {
+		sign = "-"
+		abs = -abs
+	}
+	name := fmt.Sprintf("UTC%s%02d:%02d", sign, abs/3600, abs%3600/60)
+	return time.FixedZone(name, offset)
+}
+
+// PasscodeWindow describes the validity window of a scheduled passcode,
+// expressed in the lock's time zone and aligned to the hour.
+type PasscodeWindow struct {
+	Type        PasscodeType `json:"keyboardPwdType"`
+	Start       time.Time    `json:"start"`
+	End         time.Time    `json:"end"` // zero for one-time and permanent passcodes
+	Explanation string       `json:"explanation"`
+}
+
+// ScheduledPasscodeResponse is the result of SchedulePasscode.
+type ScheduledPasscodeResponse struct {
+	RandomPasscodeResponse
+	Window PasscodeWindow `json:"window"`
+}
+
+// inLocation reinterprets the wall-clock fields of t in loc, ignoring t's own zone.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// floorHour drops minutes and below in t's own zone.
+// time.Truncate is not used because it rounds in UTC, which is wrong for half-hour offsets.
+func floorHour(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// ceilHour rounds t up to the next full hour in t's own zone.
+func ceilHour(t time.Time) time.Time {
+	f := floorHour(t)
+	if f.Equal(t) {
+		return f
+	}
+	return f.Add(time.Hour)
+}
+
+// PlanPasscodeWindow computes the passcode window for a stay.
+// checkIn and checkOut are wall-clock times in the lock's zone loc; the zone
+// attached to them is ignored. The start is rounded down and the end rounded up
+// to the hour so the passcode covers the whole stay.
+// A zero checkOut yields a permanent passcode, otherwise a period passcode.
+// If singleUse is set a one-time passcode is planned and checkOut must be zero.
+func PlanPasscodeWindow(loc *time.Location, checkIn, checkOut time.Time, singleUse bool) (*PasscodeWindow, error) {
+	if checkIn.IsZero() {
+		return nil, fmt.Errorf("check-in time is required")
+	}
+	if singleUse && !checkOut.IsZero() {
+		return nil, fmt.Errorf("one-time passcodes have no check-out time")
+	}
+
+	in := inLocation(checkIn, loc)
+	w := &PasscodeWindow{Start: floorHour(in)}
+
+	var notes []string
+	if !w.Start.Equal(in) {
+		notes = append(notes, fmt.Sprintf("check-in %s rounded down to %s", in.Format("15:04"), w.Start.Format("15:04")))
+	}
+
+	const layout = "2006-01-02 15:04"
+	switch {
+	case singleUse:
+		w.Type = PasscodeTypeOneTime
+		w.Explanation = fmt.Sprintf("one-time passcode usable once between %s and %s (lock time, %s)",
+			w.Start.Format(layout), w.Start.Add(6*time.Hour).Format(layout), loc)
+	case checkOut.IsZero():
+		w.Type = PasscodeTypePermanent
+		w.Explanation = fmt.Sprintf("permanent passcode valid from %s (lock time, %s); it must be used once before %s or it expires",
+			w.Start.Format(layout), loc, w.Start.Add(24*time.Hour).Format(layout))
+	default:
+		out := inLocation(checkOut, loc)
+		if !out.After(in) {
+			return nil, fmt.Errorf("check-out %s is not after check-in %s", out.Format(layout), in.Format(layout))
+		}
+		w.Type = PasscodeTypePeriod
+		w.End = ceilHour(out)
+		if !w.End.Equal(out) {
+			notes = append(notes, fmt.Sprintf("check-out %s rounded up to %s", out.Format("15:04"), w.End.Format("15:04")))
+		}
+		w.Explanation = fmt.Sprintf("period passcode valid from %s to %s (lock time, %s); it must be used once before %s or it expires",
+			w.Start.Format(layout), w.End.Format(layout), loc, w.Start.Add(24*time.Hour).Format(layout))
+	}
+
+	if len(notes) > 0 {
+		w.Explanation += "; " + strings.Join(notes, "; ")
+	}
+	return w, nil
+}
+
+// SchedulePasscode issues a random passcode for a stay using the lock's time zone.
+// checkIn and checkOut are wall-clock times at the lock; see PlanPasscodeWindow
+// for how they are rounded and how the passcode type is chosen.
+func (c *Client) SchedulePasscode(lockID int, pwdName string, checkIn, checkOut time.Time, singleUse bool) (*ScheduledPasscodeResponse, error) {
+	return SchedulePasscode(c, lockID, pwdName, checkIn, checkOut, singleUse)
+}
+
+// SchedulePasscode issues a passcode for a stay through s, like Client.SchedulePasscode.
+func SchedulePasscode(s Service, lockID int, pwdName string, checkIn, checkOut time.Time, singleUse bool) (*ScheduledPasscodeResponse, error) {
+	detail, err := s.GetLockDetail(lockID)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := PlanPasscodeWindow(detail.Location(), checkIn, checkOut, singleUse)
+	if err != nil {
+		return nil, err
+	}
+
+	var endDate int64
+	if !w.End.IsZero() {
+		endDate = w.End.UnixMilli()
+	}
+	resp, err := s.GetRandomPasscode(lockID, w.Type, pwdName, w.Start.UnixMilli(), endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScheduledPasscodeResponse{
+		RandomPasscodeResponse: *resp,
+		Window:                 *w,
+	}, nil
+}
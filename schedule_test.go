@@ -0,0 +1,104 @@
+package ttlock_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/immofon/ttlock"
+)
+
+func TestPlanPasscodeWindowRoundsInHalfHourZones(t *testing.T) {
+	india := (&ttlock.LockDetail{TimezoneRawOffset: 19800000}).Location()
+	w, err := ttlock.PlanPasscodeWindow(india, wallClock("2030-11-01 14:20"), wallClock("2030-11-03 11:10"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Type != ttlock.PasscodeTypePeriod {
+		t.Errorf("type %d, want a period passcode", w.Type)
+	}
+	// 14:00 and 12:00 at UTC+05:30, not the half hours UTC rounding would give
+	if want := time.Date(2030, 11, 1, 8, 30, 0, 0, time.UTC); !w.Start.Equal(want) {
+		t.Errorf("start %v, want %v", w.Start, want.In(india))
+	}
+	if want := time.Date(2030, 11, 3, 6, 30, 0, 0, time.UTC); !w.End.Equal(want) {
+		t.Errorf("end %v, want %v", w.End, want.In(india))
+	}
+	if !strings.Contains(w.Explanation, "rounded down to 14:00") || !strings.Contains(w.Explanation, "rounded up to 12:00") {
+		t.Errorf("explanation %q does not mention the rounding", w.Explanation)
+	}
+
+	newfoundland := (&ttlock.LockDetail{TimezoneRawOffset: -12600000}).Location()
+	w, err = ttlock.PlanPasscodeWindow(newfoundland, wallClock("2030-11-01 09:00"), wallClock("2030-11-01 17:00"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2030, 11, 1, 12, 30, 0, 0, time.UTC); !w.Start.Equal(want) || !w.End.Equal(want.Add(8*time.Hour)) {
+		t.Errorf("window %v to %v, unrounded times must stay as they are", w.Start, w.End)
+	}
+}
+
+func TestPlanPasscodeWindowTypes(t *testing.T) {
+	loc := time.UTC
+	in := wallClock("2030-11-01 15:00")
+
+	w, err := ttlock.PlanPasscodeWindow(loc, in, time.Time{}, true)
+	if err != nil || w.Type != ttlock.PasscodeTypeOneTime || !w.End.IsZero() {
+		t.Errorf("single use: %+v, %v", w, err)
+	}
+	w, err = ttlock.PlanPasscodeWindow(loc, in, time.Time{}, false)
+	if err != nil || w.Type != ttlock.PasscodeTypePermanent || !w.End.IsZero() {
+		t.Errorf("no check-out: %+v, %v", w, err)
+	}
+	w, err = ttlock.PlanPasscodeWindow(loc, in, in.Add(48*time.Hour), false)
+	if err != nil || w.Type != ttlock.PasscodeTypePeriod {
+		t.Errorf("with check-out: %+v, %v", w, err)
+	}
+
+	for name, out := range map[string]time.Time{
+		"check-out before check-in": in.Add(-time.Hour),
+		"check-out at check-in":     in,
+	} {
+		if _, err := ttlock.PlanPasscodeWindow(loc, in, out, false); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if _, err := ttlock.PlanPasscodeWindow(loc, in, in.Add(time.Hour), true); err == nil {
+		t.Error("single use with a check-out: no error")
+	}
+	if _, err := ttlock.PlanPasscodeWindow(loc, time.Time{}, in, false); err == nil {
+		t.Error("no check-in: no error")
+	}
+}
+
+func TestSchedulePasscodeAtTheLock(t *testing.T) {
+	srv, c := newTestServer(t)
+	lockID := srv.AddLock(passcodeLock("Front"))
+
+	resp, err := c.SchedulePasscode(lockID, "guest", wallClock("2030-11-01 15:30"), wallClock("2030-11-03 10:15"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passcodes := srv.Passcodes(lockID)
+	if len(passcodes) != 1 || passcodes[0].KeyboardPwdID != resp.KeyboardPwdID {
+		t.Fatalf("passcodes on the server: %+v", passcodes)
+	}
+	p := passcodes[0]
+	// 15:00 and 11:00 at the UTC+1 lock
+	if want := time.Date(2030, 11, 1, 14, 0, 0, 0, time.UTC).UnixMilli(); p.StartDate != want {
+		t.Errorf("start %v", time.UnixMilli(p.StartDate).UTC())
+	}
+	if want := time.Date(2030, 11, 3, 10, 0, 0, 0, time.UTC).UnixMilli(); p.EndDate != want {
+		t.Errorf("end %v", time.UnixMilli(p.EndDate).UTC())
+	}
+	if p.KeyboardPwdType != int(ttlock.PasscodeTypePeriod) {
+		t.Errorf("type %d, want a period passcode", p.KeyboardPwdType)
+	}
+
+	if _, err := c.SchedulePasscode(lockID, "late", wallClock("2030-11-03 10:00"), wallClock("2030-11-01 15:00"), false); err == nil {
+		t.Error("check-out before check-in: no error")
+	}
+	if n := len(srv.Passcodes(lockID)); n != 1 {
+		t.Errorf("%d passcodes after a rejected schedule, want 1", n)
+	}
+}
@@ -0,0 +1,35 @@
+package ttlock_test
+
+import (
+	"testing"
+
+	"github.com/immofon/ttlock"
+	"github.com/immofon/ttlock/ttlocktest"
+)
+
+// newTestServer starts a fake server with the lock admin "alice" and returns
+// it with a client logged in as alice.
+func newTestServer(t *testing.T) (*ttlocktest.Server, *ttlock.Client) {
+	t.Helper()
+	srv := ttlocktest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddUser("alice", "secret")
+
+	c, err := ttlock.Login(srv.ClientID, srv.ClientSecret, "alice", "secret", ttlock.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, c
+}
+
+// passcodeLock is a lock of alice that supports passcodes, in UTC+1
+func passcodeLock(alias string) ttlocktest.Lock {
+	return ttlocktest.Lock{
+		Owner: "alice",
+		Detail: ttlock.LockDetail{
+			LockAlias:         alias,
+			FeatureValue:      ttlock.NewFeatureSet(ttlock.LockFeaturePasscode, ttlock.LockFeatureCyclicPasscode).String(),
+			TimezoneRawOffset: 3600000,
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package ttlock
+
+// LockService is the lock part of the TTLock API.
+// Client implements it; fakes and decorators (caching, auditing) can too.
+type LockService interface {
+	GetLockList(pageNo, pageSize int, lockAlias string, groupId int) (*LockListResponse, error)
+	GetLockDetail(lockId int) (*LockDetail, error)
+	GetAccessoryBattery(lockId int) (*AccessoryBatteryResponse, error)
+}
+
+// PasscodeService is the passcode part of the TTLock API.
+type PasscodeService interface {
+	GetRandomPasscode(lockID int, pwdType PasscodeType, pwdName string, startDate, endDate int64) (*RandomPasscodeResponse, error)
+	GetPasscodeList(lockID, pageNo, pageSize, orderBy int, searchStr string) (*PasscodeListResponse, error)
+	DeletePasscode(lockID, keyboardPwdID int, deleteType PasscodeDeleteType) error
+}
+
+// KeyService is the eKey part of the TTLock API.
+type KeyService interface {
+	SendKey(lockID int, receiverUsername, keyName string, startDate, endDate int64, options *SendKeyOptions) (*SendKeyResponse, error)
+	GetKeyList(lockID, pageNo, pageSize int, searchStr string) (*KeyListResponse, error)
+	DeleteKey(keyID int) error
+	ChangeKeyPeriod(keyID int, startDate, endDate int64) error
+}
+
+// UserService is the user management part of the TTLock API.
+type UserService interface {
+	RegisterUser(username, password string) (*RegisterUserResponse, error)
+	ListUsers(startDate, endDate int64, pageNo, pageSize int) (*UserListResponse, error)
+	ResetUserPassword(username, password string) error
+	DeleteUser(username string) error
+}
+
+// Service combines the services needed by helpers that use several parts of the API.
+type Service interface {
+	LockService
+	PasscodeService
+	KeyService
+}
+
+// TokenSource supplies the access token used to call the API.
+type TokenSource interface {
+	Token() (AccessTokenResponse, error)
+}
+
+var (
+	_ Service     = (*Client)(nil)
+	_ UserService = (*Client)(nil)
+	_ TokenSource = (*Client)(nil)
+)
+
+// localized is implemented by services that choose the language of errors
+type localized interface {
+	errorLocale() Locale
+}
+
+// serviceError creates an Error in the locale of s, if it has one
+func serviceError(s interface{}, code ErrorCode) *Error {
+	if l, ok := s.(localized); ok {
+		return NewLocalizedError(code, l.errorLocale())
+	}
+	return NewError(code)
+}
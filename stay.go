@@ -0,0 +1,158 @@
+package ttlock
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StayRequest describes the access to provision for a guest stay.
+type StayRequest struct {
+	LockID   int
+	Guest    string    // guest name, used as the passcode and eKey name
+	Username string    // TTLock account of the guest; an eKey is sent only when set
+	CheckIn  time.Time // wall-clock time at the lock; its zone is ignored
+	CheckOut time.Time // wall-clock time at the lock; zero for an open-ended stay
+
+	NoPasscode bool            // do not issue a passcode even if the lock supports one
+	KeyOptions *SendKeyOptions // options of the eKey; may be nil
+}
+
+// StayPasscode is a passcode issued for a stay
+type StayPasscode struct {
+	KeyboardPwdID int            `json:"keyboardPwdId"`
+	KeyboardPwd   string         `json:"keyboardPwd"`
+	Window        PasscodeWindow `json:"window"`
+}
+
+// StayKey is an eKey sent for a stay
+type StayKey struct {
+	KeyID     int    `json:"keyId"`
+	Username  string `json:"username"`
+	StartDate int64  `json:"startDate"` // 0 for a permanent key
+	EndDate   int64  `json:"endDate"`
+}
+
+// Stay records all credentials created for a stay. Store it, e.g. as JSON
+// with the booking, and pass it to RevokeStay when the stay ends.
+type Stay struct {
+	LockID   int           `json:"lockId"`
+	Guest    string        `json:"guest"`
+	Passcode *StayPasscode `json:"passcode,omitempty"`
+	Key      *StayKey      `json:"key,omitempty"`
+}
+
+// StayRevocation reports what RevokeStay removed
+type StayRevocation struct {
+	PasscodeDeleted bool `json:"passcodeDeleted"` // the passcode is gone from the cloud
+	// PasscodeOnLock is set when the passcode could not be removed from the
+	// lock remotely (no gateway or the gateway is offline). Only its cloud
+	// record was deleted, and the lock accepts it until its window ends.
+	PasscodeOnLock bool `json:"passcodeOnLock"`
+	KeyDeleted     bool `json:"keyDeleted"`
+}
+
+// ProvisionStay issues the credentials for a stay: a passcode if the lock
+// supports passcodes (see SchedulePasscode for its window) and an eKey valid
+// from check-in to check-out if req.Username is set. If any step fails, the
+// credentials created so far are revoked and the error is returned.
+func (c *Client) ProvisionStay(req StayRequest) (*Stay, error) {
+	return ProvisionStay(c, req)
+}
+
+// ProvisionStay issues the credentials for a stay through s, like Client.ProvisionStay.
+func ProvisionStay(s Service, req StayRequest) (*Stay, error) {
+	if req.CheckIn.IsZero() {
+		return nil, fmt.Errorf("check-in time is required")
+	}
+	detail, err := s.GetLockDetail(req.LockID)
+	if err != nil {
+		return nil, err
+	}
+
+	withPasscode := !req.NoPasscode && detail.SupportsFeature(LockFeaturePasscode)
+	if !withPasscode && req.Username == "" {
+		return nil, serviceError(s, ErrLockOperationNotSupported)
+	}
+
+	stay := &Stay{LockID: req.LockID, Guest: req.Guest}
+	loc := detail.Location()
+
+	if withPasscode {
+		w, err := PlanPasscodeWindow(loc, req.CheckIn, req.CheckOut, false)
+		if err != nil {
+			return nil, err
+		}
+		var endDate int64
+		if !w.End.IsZero() {
+			endDate = w.End.UnixMilli()
+		}
+		resp, err := s.GetRandomPasscode(req.LockID, w.Type, req.Guest, w.Start.UnixMilli(), endDate)
+		if err != nil {
+			return nil, err
+		}
+		stay.Passcode = &StayPasscode{
+			KeyboardPwdID: resp.KeyboardPwdID,
+			KeyboardPwd:   resp.KeyboardPwd,
+			Window:        *w,
+		}
+	}
+
+	if req.Username != "" {
+		key := &StayKey{Username: req.Username}
+		if !req.CheckOut.IsZero() {
+			key.StartDate = inLocation(req.CheckIn, loc).UnixMilli()
+			key.EndDate = inLocation(req.CheckOut, loc).UnixMilli()
+		}
+		resp, err := s.SendKey(req.LockID, req.Username, req.Guest, key.StartDate, key.EndDate, req.KeyOptions)
+		if err != nil {
+			if _, rerr := RevokeStay(s, stay); rerr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to revoke partial stay: %w", rerr))
+			}
+			return nil, err
+		}
+		key.KeyID = resp.KeyID
+		stay.Key = key
+	}
+
+	return stay, nil
+}
+
+// RevokeStay deletes all credentials recorded in stay. Credentials that no
+// longer exist are skipped. The passcode is removed from the lock through
+// its gateway when possible; otherwise only its cloud record is deleted and
+// PasscodeOnLock is reported. All credentials are attempted even if one fails.
+func (c *Client) RevokeStay(stay *Stay) (*StayRevocation, error) {
+	return RevokeStay(c, stay)
+}
+
+// RevokeStay deletes the credentials of stay through s, like Client.RevokeStay.
+func RevokeStay(s Service, stay *Stay) (*StayRevocation, error) {
+	result := &StayRevocation{}
+	var errs []error
+
+	if p := stay.Passcode; p != nil {
+		err := s.DeletePasscode(stay.LockID, p.KeyboardPwdID, PasscodeDeleteRemote)
+		if IsGatewayError(err) {
+			result.PasscodeOnLock = true
+			err = s.DeletePasscode(stay.LockID, p.KeyboardPwdID, PasscodeDeleteBluetooth)
+		}
+		switch {
+		case err == nil || IsNotFound(err):
+			result.PasscodeDeleted = true
+		default:
+			result.PasscodeOnLock = false
+			errs = append(errs, fmt.Errorf("passcode %d: %w", p.KeyboardPwdID, err))
+		}
+	}
+
+	if k := stay.Key; k != nil {
+		if err := s.DeleteKey(k.KeyID); err == nil || IsNotFound(err) {
+			result.KeyDeleted = true
+		} else {
+			errs = append(errs, fmt.Errorf("eKey %d: %w", k.KeyID, err))
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
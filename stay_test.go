@@ -0,0 +1,108 @@
+package ttlock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/immofon/ttlock"
+)
+
+func stayRequest(lockID int) ttlock.StayRequest {
+	in := time.Date(2030, 7, 1, 15, 0, 0, 0, time.UTC)
+	return ttlock.StayRequest{
+		LockID:   lockID,
+		Guest:    "Jane",
+		Username: "jane",
+		CheckIn:  in,
+		CheckOut: in.Add(3*24*time.Hour - 4*time.Hour),
+	}
+}
+
+func TestProvisionAndRevokeStay(t *testing.T) {
+	srv, c := newTestServer(t)
+	srv.AddUser("jane", "pw")
+	lockID := srv.AddLock(passcodeLock("Front"))
+
+	stay, err := c.ProvisionStay(stayRequest(lockID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stay.Passcode == nil || stay.Key == nil {
+		t.Fatalf("stay %+v lacks a passcode or key", stay)
+	}
+	if n := len(srv.Passcodes(lockID)); n != 1 {
+		t.Fatalf("%d passcodes on the server, want 1", n)
+	}
+	keys := srv.Keys(lockID)
+	if len(keys) != 1 || keys[0].Username != "jane" {
+		t.Fatalf("keys on the server: %+v", keys)
+	}
+	// Check-in 15:00 at a UTC+1 lock
+	if want := time.Date(2030, 7, 1, 14, 0, 0, 0, time.UTC).UnixMilli(); keys[0].StartDate != want {
+		t.Errorf("key starts %v, want %v", time.UnixMilli(keys[0].StartDate).UTC(), time.UnixMilli(want).UTC())
+	}
+
+	// Without a gateway only the cloud record of the passcode is deleted
+	result, err := c.RevokeStay(stay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.PasscodeDeleted || !result.PasscodeOnLock || !result.KeyDeleted {
+		t.Errorf("revocation %+v", result)
+	}
+	if len(srv.Passcodes(lockID)) != 0 || len(srv.Keys(lockID)) != 0 {
+		t.Error("credentials left on the server")
+	}
+
+	// Revoking again skips what is already gone
+	result, err = c.RevokeStay(stay)
+	if err != nil || !result.PasscodeDeleted || !result.KeyDeleted {
+		t.Errorf("second revocation: %+v, %v", result, err)
+	}
+}
+
+func TestRevokeStayThroughGateway(t *testing.T) {
+	srv, c := newTestServer(t)
+	lock := passcodeLock("Front")
+	lock.HasGateway = true
+	lockID := srv.AddLock(lock)
+
+	req := stayRequest(lockID)
+	req.Username = ""
+	stay, err := c.ProvisionStay(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.RevokeStay(stay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.PasscodeDeleted || result.PasscodeOnLock {
+		t.Errorf("revocation %+v", result)
+	}
+}
+
+func TestProvisionStayRollsBack(t *testing.T) {
+	srv, c := newTestServer(t)
+	srv.AddUser("jane", "pw")
+	lockID := srv.AddLock(passcodeLock("Front"))
+	srv.InjectError("/v3/key/send", ttlock.ErrLockFrozen, 1)
+
+	if _, err := c.ProvisionStay(stayRequest(lockID)); !ttlock.IsErrorCode(err, ttlock.ErrLockFrozen) {
+		t.Fatalf("got %v, want ErrLockFrozen", err)
+	}
+	if n := len(srv.Passcodes(lockID)); n != 0 {
+		t.Errorf("%d passcodes left after rollback", n)
+	}
+}
+
+func TestProvisionStayWithoutCredentials(t *testing.T) {
+	srv, c := newTestServer(t)
+	lockID := srv.AddLock(passcodeLock("Front"))
+
+	req := stayRequest(lockID)
+	req.Username, req.NoPasscode = "", true
+	if _, err := c.ProvisionStay(req); !ttlock.IsErrorCode(err, ttlock.ErrLockOperationNotSupported) {
+		t.Fatalf("got %v, want ErrLockOperationNotSupported", err)
+	}
+}
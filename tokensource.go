@@ -0,0 +1,101 @@
+package ttlock
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// tokenExpiryDelta is how long before its expiry a token is replaced, so that
+// it does not expire while a request is under way
+const tokenExpiryDelta = time.Minute
+
+// Valid reports whether the token is set and not about to expire.
+func (t AccessTokenResponse) Valid() bool {
+	if t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(t.Expiry)
+}
+
+// setExpiry sets Expiry from ExpiresIn
+func (t *AccessTokenResponse) setExpiry() {
+	if t.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+}
+
+type staticTokenSource struct {
+	token AccessTokenResponse
+}
+
+// StaticTokenSource returns a TokenSource that always returns token.
+// It never refreshes; when the token has expired the API rejects it.
+func StaticTokenSource(token AccessTokenResponse) TokenSource {
+	return staticTokenSource{token: token}
+}
+
+func (s staticTokenSource) Token() (AccessTokenResponse, error) {
+	return s.token, nil
+}
+
+// reuseTokenSource returns its token while it is valid and otherwise obtains
+// a new one with fetch, which receives the previous token.
+type reuseTokenSource struct {
+	mu    sync.Mutex
+	token AccessTokenResponse
+	fetch func(previous AccessTokenResponse) (*AccessTokenResponse, error)
+}
+
+func (s *reuseTokenSource) Token() (AccessTokenResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token.Valid() {
+		return s.token, nil
+	}
+	token, err := s.fetch(s.token)
+	if err != nil {
+		return AccessTokenResponse{}, err
+	}
+	s.token = *token
+	return s.token, nil
+}
+
+// PasswordTokenSource returns a TokenSource using the password grant with the
+// credentials, BaseURL and HTTPClient of c. Tokens are reused until they
+// expire, then renewed with the refresh grant, falling back to the password
+// grant if the refresh token is rejected. c need not be logged in.
+func PasswordTokenSource(c *Client) TokenSource {
+	return &reuseTokenSource{fetch: c.passwordGrant}
+}
+
+// RefreshTokenSource returns a TokenSource using the refresh grant with the
+// client credentials, BaseURL and HTTPClient of c, starting from
+// refreshToken. Each refresh uses the refresh token returned by the previous
+// one. c need not be logged in.
+func RefreshTokenSource(c *Client, refreshToken string) TokenSource {
+	return &reuseTokenSource{
+		token: AccessTokenResponse{RefreshToken: refreshToken},
+		fetch: c.refreshGrant,
+	}
+}
+
+// passwordGrant renews previous with the refresh grant if possible and
+// otherwise logs in with the password
+func (c *Client) passwordGrant(previous AccessTokenResponse) (*AccessTokenResponse, error) {
+	if previous.RefreshToken != "" {
+		token, err := c.RefreshAccessToken(previous.RefreshToken)
+		if err == nil {
+			return token, nil
+		}
+	}
+	return c.GetAccessToken()
+}
+
+// refreshGrant renews previous with the refresh grant
+func (c *Client) refreshGrant(previous AccessTokenResponse) (*AccessTokenResponse, error) {
+	if previous.RefreshToken == "" {
+		return nil, errors.New("ttlock: no refresh token")
+	}
+	return c.RefreshAccessToken(previous.RefreshToken)
+}
@@ -0,0 +1,105 @@
+package ttlock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/immofon/ttlock"
+	"github.com/immofon/ttlock/ttlocktest"
+)
+
+func TestLoginRefreshesExpiringToken(t *testing.T) {
+	srv := ttlocktest.NewServer()
+	defer srv.Close()
+	srv.AddUser("alice", "secret")
+	// Tokens are replaced a minute before they expire, so this one is
+	// renewed after a second
+	srv.SetTokenTTL(61 * time.Second)
+
+	c, err := ttlock.Login(srv.ClientID, srv.ClientSecret, "alice", "secret", ttlock.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := c.AccessToken()
+
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := c.GetLockList(1, 20, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessToken() == first {
+		t.Error("token was not renewed")
+	}
+	if n := srv.Requests("/oauth2/token"); n != 2 {
+		t.Errorf("%d token requests, want 2", n)
+	}
+}
+
+func TestExpiredTokenIsRejected(t *testing.T) {
+	srv, c := newTestServer(t)
+	srv.ExpireTokens()
+
+	_, err := c.GetLockList(1, 20, "", 0)
+	if !ttlock.IsAuthError(err) {
+		t.Fatalf("got %v, want an auth error", err)
+	}
+}
+
+func TestRefreshTokenSource(t *testing.T) {
+	srv, login := newTestServer(t)
+	srv.AddLock(ttlocktest.Lock{Owner: "alice"})
+	token, err := login.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ExpireTokens()
+
+	app := &ttlock.Client{ClientID: srv.ClientID, ClientSecret: srv.ClientSecret, BaseURL: srv.URL}
+	c := ttlock.NewClientWithTokenSource(srv.ClientID, ttlock.RefreshTokenSource(app, token.RefreshToken), ttlock.WithBaseURL(srv.URL))
+	resp, err := c.GetLockList(1, 20, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.List) != 1 {
+		t.Errorf("%d locks, want 1", len(resp.List))
+	}
+}
+
+func TestPasswordTokenSourceFallsBackToPassword(t *testing.T) {
+	srv := ttlocktest.NewServer()
+	defer srv.Close()
+	srv.AddUser("alice", "secret")
+	srv.SetTokenTTL(61 * time.Second)
+
+	app := &ttlock.Client{ClientID: srv.ClientID, ClientSecret: srv.ClientSecret, Username: "alice", Password: "secret", BaseURL: srv.URL}
+	ts := ttlock.PasswordTokenSource(app)
+	if _, err := ts.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The refresh grant fails, so the password grant is used again
+	srv.InjectError("/oauth2/token", ttlock.ErrInvalidRefreshToken, 1)
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := ts.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests("/oauth2/token"); n != 3 {
+		t.Errorf("%d token requests, want 3", n)
+	}
+}
+
+func TestStaticTokenSource(t *testing.T) {
+	srv, login := newTestServer(t)
+	token, err := login.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := ttlock.NewClientWithTokenSource(srv.ClientID, ttlock.StaticTokenSource(token), ttlock.WithBaseURL(srv.URL))
+	if _, err := c.GetLockList(1, 20, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	srv.ExpireTokens()
+	if _, err := c.GetLockList(1, 20, "", 0); !ttlock.IsAuthError(err) {
+		t.Fatalf("got %v, want an auth error", err)
+	}
+}
@@ -0,0 +1,657 @@
+package ttlocktest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/immofon/ttlock"
+)
+
+// errorResponse is the body of a failed API call
+type errorResponse struct {
+	Errcode     int    `json:"errcode"`
+	Errmsg      string `json:"errmsg"`
+	Description string `json:"description"`
+}
+
+// listResponse is the body of a paged list API call
+type listResponse[T any] struct {
+	List     []T `json:"list"`
+	PageNo   int `json:"pageNo"`
+	PageSize int `json:"pageSize"`
+	Pages    int `json:"pages"`
+	Total    int `json:"total"`
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an API error. Like the real server, errors are sent with
+// HTTP status 200.
+func writeError(w http.ResponseWriter, code ttlock.ErrorCode) {
+	writeJSON(w, errorResponse{
+		Errcode:     int(code),
+		Errmsg:      code.Message(ttlock.LocaleEN),
+		Description: code.Message(ttlock.LocaleZhCN),
+	})
+}
+
+// newToken returns a random hex token
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// formInt parses an optional integer parameter; def is returned when it is missing.
+func formInt(r *http.Request, name string, def int) (int, error) {
+	v := r.FormValue(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// formInt64 parses an optional int64 parameter, returning 0 when it is missing.
+func formInt64(r *http.Request, name string) (int64, error) {
+	v := r.FormValue(name)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// paginate returns the requested page of items
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	pageNo, err1 := formInt(r, "pageNo", 1)
+	pageSize, err2 := formInt(r, "pageSize", 20)
+	if err1 != nil || err2 != nil || pageNo < 1 || pageSize < 1 {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+
+	total := len(items)
+	start := min((pageNo-1)*pageSize, total)
+	end := min(start+pageSize, total)
+	page := append([]T{}, items[start:end]...)
+	writeJSON(w, listResponse[T]{
+		List:     page,
+		PageNo:   pageNo,
+		PageSize: pageSize,
+		Pages:    (total + pageSize - 1) / pageSize,
+		Total:    total,
+	})
+}
+
+// handleToken serves the password and refresh_token grants of /oauth2/token
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.FormValue("clientId") != s.ClientID || r.FormValue("clientSecret") != s.ClientSecret {
+		writeError(w, ttlock.ErrInvalidClient)
+		return
+	}
+
+	var username string
+	switch grant := r.FormValue("grant_type"); grant {
+	case "", "password":
+		username = r.FormValue("username")
+		u, ok := s.users[username]
+		if !ok || !strings.EqualFold(u.passwordMD5, r.FormValue("password")) {
+			writeError(w, ttlock.ErrInvalidUsernameOrPass)
+			return
+		}
+	case "refresh_token":
+		refresh := r.FormValue("refresh_token")
+		var ok bool
+		if username, ok = s.refreshes[refresh]; !ok {
+			writeError(w, ttlock.ErrInvalidRefreshToken)
+			return
+		}
+		delete(s.refreshes, refresh)
+	default:
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+
+	accessToken, refreshToken := newToken(), newToken()
+	s.tokens[accessToken] = &token{username: username, expires: s.now().Add(s.tokenTTL)}
+	s.refreshes[refreshToken] = username
+	writeJSON(w, ttlock.AccessTokenResponse{
+		AccessToken:  accessToken,
+		UID:          s.users[username].uid,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.tokenTTL / time.Second),
+	})
+}
+
+// authorized checks the clientId, accessToken and date parameters shared by
+// all API calls, then calls next with s.mu held and the caller's username.
+func (s *Server) authorized(next func(w http.ResponseWriter, r *http.Request, username string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.FormValue("clientId") != s.ClientID {
+			writeError(w, ttlock.ErrClientIDNotExist)
+			return
+		}
+		t, ok := s.tokens[r.FormValue("accessToken")]
+		if !ok {
+			writeError(w, ttlock.ErrTokenNotExist)
+			return
+		}
+		if !s.now().Before(t.expires) {
+			writeError(w, ttlock.ErrTokenUnauthorized)
+			return
+		}
+		if !s.checkDate(w, r) {
+			return
+		}
+
+		next(w, r, t.username)
+	}
+}
+
+// appAuthorized checks the clientId, clientSecret and date parameters of the
+// user management APIs, then calls next with s.mu held.
+func (s *Server) appAuthorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.FormValue("clientId") != s.ClientID || r.FormValue("clientSecret") != s.ClientSecret {
+			writeError(w, ttlock.ErrInvalidClient)
+			return
+		}
+		if !s.checkDate(w, r) {
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// checkDate validates the date parameter against the server's clock,
+// writing an error if it is invalid. s.mu must be held.
+func (s *Server) checkDate(w http.ResponseWriter, r *http.Request) bool {
+	date, err := strconv.ParseInt(r.FormValue("date"), 10, 64)
+	if err != nil {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return false
+	}
+	if skew := s.now().Sub(time.UnixMilli(date)); skew > s.maxSkew || skew < -s.maxSkew {
+		writeError(w, ttlock.ErrInvalidRequestTime)
+		return false
+	}
+	return true
+}
+
+// ownedLock returns the lock named by the lockId parameter if username
+// administers it, writing an error otherwise. s.mu must be held.
+func (s *Server) ownedLock(w http.ResponseWriter, r *http.Request, username string) (*Lock, bool) {
+	lockID, err := strconv.Atoi(r.FormValue("lockId"))
+	if err != nil {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return nil, false
+	}
+	lock, ok := s.locks[lockID]
+	if !ok {
+		writeError(w, ttlock.ErrLockNotExist)
+		return nil, false
+	}
+	if lock.Owner != username {
+		writeError(w, ttlock.ErrPermissionDenied)
+		return nil, false
+	}
+	return lock, true
+}
+
+func (s *Server) handleLockList(w http.ResponseWriter, r *http.Request, username string) {
+	alias := r.FormValue("lockAlias")
+	groupID, err := formInt(r, "groupId", 0)
+	if err != nil {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+
+	locks := []ttlock.Lock{}
+	for _, id := range s.lockIDs(username) {
+		lock := s.locks[id]
+		if alias != "" && !strings.Contains(lock.Detail.LockAlias, alias) {
+			continue
+		}
+		if groupID != 0 && lock.GroupID != groupID {
+			continue
+		}
+		locks = append(locks, lock.summary())
+	}
+	paginate(w, r, locks)
+}
+
+func (s *Server) handleLockDetail(w http.ResponseWriter, r *http.Request, username string) {
+	lock, ok := s.ownedLock(w, r, username)
+	if !ok {
+		return
+	}
+	writeJSON(w, lock.Detail)
+}
+
+func (s *Server) handleAccessoryBattery(w http.ResponseWriter, r *http.Request, username string) {
+	lock, ok := s.ownedLock(w, r, username)
+	if !ok {
+		return
+	}
+	writeJSON(w, ttlock.AccessoryBatteryResponse{
+		List: append([]ttlock.AccessoryBattery{}, lock.Accessories...),
+	})
+}
+
+func (s *Server) handlePasscodeList(w http.ResponseWriter, r *http.Request, username string) {
+	lock, ok := s.ownedLock(w, r, username)
+	if !ok {
+		return
+	}
+	orderBy, err := formInt(r, "orderBy", 1)
+	if err != nil {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+	search := r.FormValue("searchStr")
+
+	passcodes := []ttlock.Passcode{}
+	for _, p := range s.passcodes[lock.Detail.LockID] {
+		if search != "" && !strings.Contains(p.KeyboardPwdName, search) && !strings.Contains(p.KeyboardPwd, search) {
+			continue
+		}
+		passcodes = append(passcodes, p)
+	}
+
+	// orderBy: 0-按名称升序、1-按时间倒序、2-按名称倒序
+	switch orderBy {
+	case 0:
+		sort.SliceStable(passcodes, func(i, j int) bool { return passcodes[i].KeyboardPwdName < passcodes[j].KeyboardPwdName })
+	case 2:
+		sort.SliceStable(passcodes, func(i, j int) bool { return passcodes[i].KeyboardPwdName > passcodes[j].KeyboardPwdName })
+	default:
+		sort.SliceStable(passcodes, func(i, j int) bool { return passcodes[i].SendDate > passcodes[j].SendDate })
+	}
+	paginate(w, r, passcodes)
+}
+
+func (s *Server) handleRandomPasscode(w http.ResponseWriter, r *http.Request, username string) {
+	lock, ok := s.ownedLock(w, r, username)
+	if !ok {
+		return
+	}
+	pwdType, err1 := formInt(r, "keyboardPwdType", 0)
+	startDate, err2 := formInt64(r, "startDate")
+	endDate, err3 := formInt64(r, "endDate")
+	if err1 != nil || err2 != nil || err3 != nil || pwdType < int(ttlock.PasscodeTypeOneTime) || pwdType > int(ttlock.PasscodeTypeSundayCyclic) {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+
+	switch ttlock.PasscodeType(pwdType) {
+	case ttlock.PasscodeTypeOneTime, ttlock.PasscodeTypePermanent, ttlock.PasscodeTypeDelete:
+	default:
+		if endDate <= startDate {
+			writeError(w, ttlock.ErrInvalidParameter)
+			return
+		}
+	}
+	if pwdType >= int(ttlock.PasscodeTypeWeekendCyclic) && lock.Detail.FeatureValue != "" {
+		features, err := ttlock.ParseFeatureSet(lock.Detail.FeatureValue)
+		if err != nil || !features.Has(ttlock.LockFeatureCyclicPasscode) {
+			writeError(w, ttlock.ErrLockOperationNotSupported)
+			return
+		}
+	}
+
+	lockID := lock.Detail.LockID
+	s.nextID++
+	p := ttlock.Passcode{
+		KeyboardPwdID:   s.nextID,
+		LockID:          lockID,
+		KeyboardPwd:     s.randomPasscode(lockID),
+		KeyboardPwdName: r.FormValue("keyboardPwdName"),
+		KeyboardPwdType: pwdType,
+		StartDate:       startDate,
+		EndDate:         endDate,
+		SendDate:        s.now().UnixMilli(),
+		SenderUsername:  username,
+	}
+	s.passcodes[lockID] = append(s.passcodes[lockID], p)
+	writeJSON(w, ttlock.RandomPasscodeResponse{
+		KeyboardPwd:   p.KeyboardPwd,
+		KeyboardPwdID: p.KeyboardPwdID,
+	})
+}
+
+// randomPasscode returns a 7 digit passcode unused on the lock. s.mu must be held.
+func (s *Server) randomPasscode(lockID int) string {
+	for {
+		pwd := fmt.Sprintf("%07d", mathrand.IntN(10000000))
+		used := false
+		for _, p := range s.passcodes[lockID] {
+			used = used || p.KeyboardPwd == pwd
+		}
+		if !used {
+			return pwd
+		}
+	}
+}
+
+func (s *Server) handleDeletePasscode(w http.ResponseWriter, r *http.Request, username string) {
+	lock, ok := s.ownedLock(w, r, username)
+	if !ok {
+		return
+	}
+	pwdID, err1 := formInt(r, "keyboardPwdId", 0)
+	deleteType, err2 := formInt(r, "deleteType", 0)
+	if err1 != nil || err2 != nil {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+	switch ttlock.PasscodeDeleteType(deleteType) {
+	case ttlock.PasscodeDeleteBluetooth:
+	case ttlock.PasscodeDeleteRemote:
+		if !lock.HasGateway {
+			writeError(w, ttlock.ErrNoAvailableGateway)
+			return
+		}
+	default:
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+
+	lockID := lock.Detail.LockID
+	for i, p := range s.passcodes[lockID] {
+		if p.KeyboardPwdID == pwdID {
+			s.passcodes[lockID] = append(s.passcodes[lockID][:i:i], s.passcodes[lockID][i+1:]...)
+			writeJSON(w, errorResponse{})
+			return
+		}
+	}
+	writeError(w, ttlock.ErrPasscodeNotExist)
+}
+
+func (s *Server) handleKeyList(w http.ResponseWriter, r *http.Request, username string) {
+	lock, ok := s.ownedLock(w, r, username)
+	if !ok {
+		return
+	}
+	search := r.FormValue("searchStr")
+
+	keys := []ttlock.Key{}
+	for _, k := range s.keys[lock.Detail.LockID] {
+		if search != "" && !strings.Contains(k.Username, search) && !strings.Contains(k.KeyName, search) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	paginate(w, r, keys)
+}
+
+func (s *Server) handleSendKey(w http.ResponseWriter, r *http.Request, username string) {
+	lock, ok := s.ownedLock(w, r, username)
+	if !ok {
+		return
+	}
+	startDate, err1 := formInt64(r, "startDate")
+	endDate, err2 := formInt64(r, "endDate")
+	keyRight, err3 := formInt(r, "keyRight", 0)
+	remoteEnable, err4 := formInt(r, "remoteEnable", 0)
+	createUser, err5 := formInt(r, "createUser", 2)
+	keyName := r.FormValue("keyName")
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil || keyName == "" {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+	// startDate and endDate are both 0 for a permanent key
+	if (startDate != 0 || endDate != 0) && endDate <= startDate {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+	switch keyType, err := formInt(r, "keyType", ttlock.KeyTypePeriod); {
+	case err != nil:
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	case keyType == ttlock.KeyTypeCyclic:
+		if startDate == 0 || !validCyclicConfig(r.FormValue("cyclicConfig")) {
+			writeError(w, ttlock.ErrInvalidParameter)
+			return
+		}
+	case keyType != ttlock.KeyTypePeriod:
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+
+	receiver := r.FormValue("receiverUsername")
+	if receiver == username {
+		writeError(w, ttlock.ErrCannotSendKeyToSelf)
+		return
+	}
+	u, ok := s.users[receiver]
+	if !ok {
+		if createUser != 1 || receiver == "" {
+			writeError(w, ttlock.ErrReceiverNotRegistered)
+			return
+		}
+		s.nextID++
+		u = &user{uid: s.nextID}
+		s.users[receiver] = u
+	}
+
+	lockID := lock.Detail.LockID
+	s.nextID++
+	k := ttlock.Key{
+		KeyID:          s.nextID,
+		LockID:         lockID,
+		UID:            u.uid,
+		Username:       receiver,
+		KeyName:        keyName,
+		KeyStatus:      "110401",
+		StartDate:      startDate,
+		EndDate:        endDate,
+		KeyRight:       keyRight,
+		RemoteEnable:   remoteEnable,
+		Remarks:        r.FormValue("remarks"),
+		SenderUsername: username,
+		Date:           s.now().UnixMilli(),
+	}
+	s.keys[lockID] = append(s.keys[lockID], k)
+	writeJSON(w, ttlock.SendKeyResponse{KeyID: k.KeyID})
+}
+
+// validCyclicConfig reports whether s is a non-empty JSON list of valid cyclic key periods
+func validCyclicConfig(s string) bool {
+	var periods []ttlock.CyclicKeyPeriod
+	if err := json.Unmarshal([]byte(s), &periods); err != nil || len(periods) == 0 {
+		return false
+	}
+	for _, p := range periods {
+		if p.Weekday < 1 || p.Weekday > 7 || p.StartTime < 0 || p.EndTime > 24*60 || p.EndTime <= p.StartTime {
+			return false
+		}
+	}
+	return true
+}
+
+// isMD5 reports whether s looks like a hex MD5 hash
+func isMD5(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// isAlphanumeric reports whether s is a non-empty string of ASCII letters and digits
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// registeredUser returns the app-registered user named by the username
+// parameter, writing an error otherwise. s.mu must be held.
+func (s *Server) registeredUser(w http.ResponseWriter, r *http.Request) (*user, bool) {
+	u, ok := s.users[r.FormValue("username")]
+	if !ok || !u.registered {
+		writeError(w, ttlock.ErrInvalidDeleteUserID)
+		return nil, false
+	}
+	return u, true
+}
+
+func (s *Server) handleRegisterUser(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("username")
+	if !isAlphanumeric(name) {
+		writeError(w, ttlock.ErrInvalidUsernameFormat)
+		return
+	}
+	password := r.FormValue("password")
+	if !isMD5(password) {
+		writeError(w, ttlock.ErrPasswordMustBeMD5)
+		return
+	}
+	username := s.Prefix + name
+	if _, ok := s.users[username]; ok {
+		writeError(w, ttlock.ErrUserAlreadyExists)
+		return
+	}
+
+	s.nextID++
+	s.users[username] = &user{
+		uid:         s.nextID,
+		passwordMD5: strings.ToLower(password),
+		registered:  true,
+		regtime:     s.now().UnixMilli(),
+	}
+	writeJSON(w, ttlock.RegisterUserResponse{Username: username})
+}
+
+func (s *Server) handleUserList(w http.ResponseWriter, r *http.Request) {
+	startDate, err1 := formInt64(r, "startDate")
+	endDate, err2 := formInt64(r, "endDate")
+	if err1 != nil || err2 != nil {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+
+	users := []ttlock.User{}
+	for username, u := range s.users {
+		if !u.registered || (startDate != 0 && u.regtime < startDate) || (endDate != 0 && u.regtime > endDate) {
+			continue
+		}
+		users = append(users, ttlock.User{UserID: u.uid, Username: username, Regtime: u.regtime})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+	paginate(w, r, users)
+}
+
+func (s *Server) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	u, ok := s.registeredUser(w, r)
+	if !ok {
+		return
+	}
+	password := r.FormValue("password")
+	if !isMD5(password) {
+		writeError(w, ttlock.ErrPasswordMustBeMD5)
+		return
+	}
+	u.passwordMD5 = strings.ToLower(password)
+	writeJSON(w, errorResponse{})
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.registeredUser(w, r); !ok {
+		return
+	}
+	username := r.FormValue("username")
+	if len(s.lockIDs(username)) > 0 {
+		writeError(w, ttlock.ErrDeleteOrTransferLocks)
+		return
+	}
+	delete(s.users, username)
+	for access, t := range s.tokens {
+		if t.username == username {
+			delete(s.tokens, access)
+		}
+	}
+	for refresh, owner := range s.refreshes {
+		if owner == username {
+			delete(s.refreshes, refresh)
+		}
+	}
+	writeJSON(w, errorResponse{})
+}
+
+// ownedKey returns the lock ID and index of the eKey named by the keyId
+// parameter if username administers its lock, writing an error otherwise.
+// s.mu must be held.
+func (s *Server) ownedKey(w http.ResponseWriter, r *http.Request, username string) (int, int, bool) {
+	keyID, err := formInt(r, "keyId", 0)
+	if err != nil {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return 0, 0, false
+	}
+	for lockID, keys := range s.keys {
+		for i, k := range keys {
+			if k.KeyID != keyID {
+				continue
+			}
+			if s.locks[lockID] == nil || s.locks[lockID].Owner != username {
+				writeError(w, ttlock.ErrPermissionDenied)
+				return 0, 0, false
+			}
+			return lockID, i, true
+		}
+	}
+	writeError(w, ttlock.ErrKeyNotExist)
+	return 0, 0, false
+}
+
+func (s *Server) handleDeleteKey(w http.ResponseWriter, r *http.Request, username string) {
+	lockID, i, ok := s.ownedKey(w, r, username)
+	if !ok {
+		return
+	}
+	keys := s.keys[lockID]
+	s.keys[lockID] = append(keys[:i:i], keys[i+1:]...)
+	writeJSON(w, errorResponse{})
+}
+
+func (s *Server) handleChangeKeyPeriod(w http.ResponseWriter, r *http.Request, username string) {
+	lockID, i, ok := s.ownedKey(w, r, username)
+	if !ok {
+		return
+	}
+	startDate, err1 := formInt64(r, "startDate")
+	endDate, err2 := formInt64(r, "endDate")
+	if err1 != nil || err2 != nil || ((startDate != 0 || endDate != 0) && endDate <= startDate) {
+		writeError(w, ttlock.ErrInvalidParameter)
+		return
+	}
+	k := &s.keys[lockID][i]
+	k.StartDate, k.EndDate = startDate, endDate
+	writeJSON(w, errorResponse{})
+}
@@ -0,0 +1,361 @@
+// Package ttlocktest provides an in-process fake TTLock cloud server for
+// tests. It keeps accounts, locks, passcodes and eKeys in memory, validates
+// credentials, access tokens and the date parameter like the real API, and
+// can inject errors and latency.
+//
+//	srv := ttlocktest.NewServer()
+//	defer srv.Close()
+//	srv.AddUser("alice", "secret")
+//	lockID := srv.AddLock(ttlocktest.Lock{Owner: "alice"})
+//	c, err := ttlock.Login(srv.ClientID, srv.ClientSecret, "alice", "secret",
+//		ttlock.WithBaseURL(srv.URL))
+package ttlocktest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/immofon/ttlock"
+)
+
+const (
+	DefaultClientID     = "test-client-id"
+	DefaultClientSecret = "test-client-secret"
+	DefaultPrefix       = "test_"             // 应用注册用户的用户名前缀
+	DefaultTokenTTL     = 90 * 24 * time.Hour // 访问令牌有效期
+	DefaultMaxSkew      = 5 * time.Minute     // date 参数允许的误差
+)
+
+// Lock is the server-side state of a lock.
+type Lock struct {
+	Owner       string            // username of the lock admin
+	Detail      ttlock.LockDetail // LockID is assigned by AddLock when 0
+	GroupID     int
+	GroupName   string
+	HasGateway  bool
+	LockData    string
+	Accessories []ttlock.AccessoryBattery
+}
+
+// summary returns the lock as listed by /v3/lock/list
+func (l *Lock) summary() ttlock.Lock {
+	hasGateway := 0
+	if l.HasGateway {
+		hasGateway = 1
+	}
+	return ttlock.Lock{
+		LockID:           l.Detail.LockID,
+		LockName:         l.Detail.LockName,
+		LockAlias:        l.Detail.LockAlias,
+		LockMac:          l.Detail.LockMac,
+		ElectricQuantity: l.Detail.ElectricQuantity,
+		FeatureValue:     l.Detail.FeatureValue,
+		HasGateway:       hasGateway,
+		LockData:         l.LockData,
+		GroupID:          l.GroupID,
+		GroupName:        l.GroupName,
+		Date:             l.Detail.Date,
+	}
+}
+
+type user struct {
+	uid         int
+	passwordMD5 string
+	registered  bool  // registered through /v3/user/register
+	regtime     int64 // milliseconds
+}
+
+type token struct {
+	username string
+	expires  time.Time
+}
+
+type fault struct {
+	code      ttlock.ErrorCode
+	remaining int // <= 0 means until cleared
+}
+
+// Server is a fake TTLock cloud server backed by an httptest.Server.
+// All methods are safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+	Prefix       string // added to usernames registered through /v3/user/register
+
+	mu        sync.Mutex
+	now       func() time.Time
+	tokenTTL  time.Duration
+	maxSkew   time.Duration
+	latency   time.Duration
+	nextID    int
+	users     map[string]*user
+	tokens    map[string]*token // access token -> token
+	refreshes map[string]string // refresh token -> username
+	locks     map[int]*Lock
+	passcodes map[int][]ttlock.Passcode // lock ID -> passcodes
+	keys      map[int][]ttlock.Key      // lock ID -> eKeys
+	faults    map[string]*fault         // path -> fault; "" matches every path
+	requests  map[string]int            // path -> number of requests
+}
+
+// NewServer starts a fake server with DefaultClientID and DefaultClientSecret.
+// Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		ClientID:     DefaultClientID,
+		ClientSecret: DefaultClientSecret,
+		Prefix:       DefaultPrefix,
+		now:          time.Now,
+		tokenTTL:     DefaultTokenTTL,
+		maxSkew:      DefaultMaxSkew,
+		nextID:       1000,
+		users:        make(map[string]*user),
+		tokens:       make(map[string]*token),
+		refreshes:    make(map[string]string),
+		locks:        make(map[int]*Lock),
+		passcodes:    make(map[int][]ttlock.Passcode),
+		keys:         make(map[int][]ttlock.Key),
+		faults:       make(map[string]*fault),
+		requests:     make(map[string]int),
+	}
+	s.Server = httptest.NewServer(s.handler())
+	return s
+}
+
+// SetClock replaces the server's clock, which is used to validate the date
+// parameter and token expiry, and is sent in the Date header.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SetTokenTTL sets the lifetime of access tokens issued from now on.
+func (s *Server) SetTokenTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenTTL = ttl
+}
+
+// SetMaxSkew sets how far the date parameter may be from the server's clock
+// before requests fail with ttlock.ErrInvalidRequestTime.
+func (s *Server) SetMaxSkew(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxSkew = d
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// InjectError makes the next times requests to path fail with code. An empty
+// path matches every endpoint; times <= 0 fails until ClearErrors is called.
+func (s *Server) InjectError(path string, code ttlock.ErrorCode, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[path] = &fault{code: code, remaining: times}
+}
+
+// ClearErrors removes all injected errors.
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.faults)
+}
+
+// Requests returns the number of requests received for path, or for all
+// paths when path is empty.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path != "" {
+		return s.requests[path]
+	}
+	n := 0
+	for _, count := range s.requests {
+		n += count
+	}
+	return n
+}
+
+// AddUser registers an account with a plain text password and returns its uid.
+func (s *Server) AddUser(username, password string) int {
+	sum := md5.Sum([]byte(password))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.users[username] = &user{uid: s.nextID, passwordMD5: hex.EncodeToString(sum[:]), regtime: s.now().UnixMilli()}
+	return s.nextID
+}
+
+// HasUser reports whether an account exists.
+func (s *Server) HasUser(username string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.users[username]
+	return ok
+}
+
+// ExpireTokens invalidates all access tokens issued so far. Refresh tokens
+// stay valid.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		t.expires = time.Time{}
+	}
+}
+
+// AddLock stores a lock and returns its ID, assigning one when
+// lock.Detail.LockID is 0.
+func (s *Server) AddLock(lock Lock) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lock.Detail.LockID == 0 {
+		s.nextID++
+		lock.Detail.LockID = s.nextID
+	}
+	if lock.Detail.Date == 0 {
+		lock.Detail.Date = s.now().UnixMilli()
+	}
+	s.locks[lock.Detail.LockID] = &lock
+	return lock.Detail.LockID
+}
+
+// UpdateLock calls update with the stored state of a lock. It reports false
+// if the lock does not exist.
+func (s *Server) UpdateLock(lockID int, update func(*Lock)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lock, ok := s.locks[lockID]
+	if ok {
+		update(lock)
+	}
+	return ok
+}
+
+// AddPasscode stores a passcode and returns its ID, assigning one when
+// p.KeyboardPwdID is 0.
+func (s *Server) AddPasscode(p ttlock.Passcode) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.KeyboardPwdID == 0 {
+		s.nextID++
+		p.KeyboardPwdID = s.nextID
+	}
+	s.passcodes[p.LockID] = append(s.passcodes[p.LockID], p)
+	return p.KeyboardPwdID
+}
+
+// Passcodes returns the passcodes of a lock in creation order.
+func (s *Server) Passcodes(lockID int) []ttlock.Passcode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ttlock.Passcode(nil), s.passcodes[lockID]...)
+}
+
+// AddKey stores an eKey and returns its ID, assigning one when k.KeyID is 0.
+func (s *Server) AddKey(k ttlock.Key) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k.KeyID == 0 {
+		s.nextID++
+		k.KeyID = s.nextID
+	}
+	s.keys[k.LockID] = append(s.keys[k.LockID], k)
+	return k.KeyID
+}
+
+// Keys returns the eKeys of a lock in creation order.
+func (s *Server) Keys(lockID int) []ttlock.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ttlock.Key(nil), s.keys[lockID]...)
+}
+
+// lockIDs returns the IDs of the locks owned by username in ascending order.
+// s.mu must be held.
+func (s *Server) lockIDs(username string) []int {
+	var ids []int
+	for id, lock := range s.locks {
+		if lock.Owner == username {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// takeFault returns the injected error for path, if any. s.mu must be held.
+func (s *Server) takeFault(path string) (ttlock.ErrorCode, bool) {
+	for _, p := range []string{path, ""} {
+		f, ok := s.faults[p]
+		if !ok {
+			continue
+		}
+		if f.remaining > 0 {
+			f.remaining--
+			if f.remaining == 0 {
+				delete(s.faults, p)
+			}
+		}
+		return f.code, true
+	}
+	return 0, false
+}
+
+// handler returns the HTTP handler serving all endpoints
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /oauth2/token", s.handleToken)
+	mux.HandleFunc("GET /v3/lock/list", s.authorized(s.handleLockList))
+	mux.HandleFunc("GET /v3/lock/detail", s.authorized(s.handleLockDetail))
+	mux.HandleFunc("GET /v3/lock/queryAccessoryElectricQuantity", s.authorized(s.handleAccessoryBattery))
+	mux.HandleFunc("GET /v3/lock/listKeyboardPwd", s.authorized(s.handlePasscodeList))
+	mux.HandleFunc("POST /v3/keyboardPwd/get", s.authorized(s.handleRandomPasscode))
+	mux.HandleFunc("GET /v3/lock/listKey", s.authorized(s.handleKeyList))
+	mux.HandleFunc("POST /v3/keyboardPwd/delete", s.authorized(s.handleDeletePasscode))
+	mux.HandleFunc("POST /v3/key/send", s.authorized(s.handleSendKey))
+	mux.HandleFunc("POST /v3/key/delete", s.authorized(s.handleDeleteKey))
+	mux.HandleFunc("POST /v3/key/changePeriod", s.authorized(s.handleChangeKeyPeriod))
+	mux.HandleFunc("POST /v3/user/register", s.appAuthorized(s.handleRegisterUser))
+	mux.HandleFunc("POST /v3/user/list", s.appAuthorized(s.handleUserList))
+	mux.HandleFunc("POST /v3/user/resetPassword", s.appAuthorized(s.handleResetPassword))
+	mux.HandleFunc("POST /v3/user/delete", s.appAuthorized(s.handleDeleteUser))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		latency := s.latency
+		code, failed := s.takeFault(r.URL.Path)
+		now := s.now()
+		s.mu.Unlock()
+
+		// Like the real server, report the server's time so clients can estimate skew
+		w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if failed {
+			writeError(w, code)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
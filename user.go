@@ -0,0 +1,160 @@
+package ttlock
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// User represents an account registered under the app's client ID
+type User struct {
+	UserID   int    `json:"userid"`   // 用户ID
+	Username string `json:"username"` // 用户名，带有应用前缀
+	Regtime  int64  `json:"regtime"`  // 注册时间（毫秒时间戳）
+}
+
+// RegisterUserResponse represents the response for registering a user
+type RegisterUserResponse struct {
+	// Username is the registered username with the prefix the platform adds
+	// for the app, e.g. "abc_alice" for "alice". Use it to log in and to
+	// send eKeys to the user.
+	Username string `json:"username"`
+	Errcode  int    `json:"errcode"`
+	Errmsg   string `json:"errmsg"`
+}
+
+// UserListResponse represents the response for the user list API
+type UserListResponse struct {
+	List     []User `json:"list"`
+	PageNo   int    `json:"pageNo"`
+	PageSize int    `json:"pageSize"`
+	Pages    int    `json:"pages"`
+	Total    int    `json:"total"`
+	Errcode  int    `json:"errcode"`
+	Errmsg   string `json:"errmsg"`
+}
+
+// userForm returns the parameters shared by the user management APIs, which
+// authenticate with the client secret instead of an access token
+func (c *Client) userForm() url.Values {
+	data := url.Values{}
+	data.Set("clientId", c.ClientID)
+	data.Set("clientSecret", c.ClientSecret)
+	data.Set("date", c.date())
+	return data
+}
+
+// postUserForm posts data to a user management endpoint and decodes the response into out
+func (c *Client) postUserForm(path string, data url.Values, out interface{}) error {
+	req, err := http.NewRequest("POST", c.BaseURL+path, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.do(req, out)
+}
+
+// RegisterUser registers a user under the app's client ID.
+// username may only contain letters and digits (ErrInvalidUsernameFormat).
+// The password should be the plain text password; it will be MD5 hashed automatically.
+// The returned Username carries the app prefix added by the platform.
+func (c *Client) RegisterUser(username, password string) (*RegisterUserResponse, error) {
+	data := c.userForm()
+	data.Set("username", username)
+	data.Set("password", hashPassword(password))
+
+	var result RegisterUserResponse
+	if err := c.postUserForm("/v3/user/register", data, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ListUsers retrieves the users registered under the app's client ID.
+// startDate and endDate filter by registration time (timestamps in
+// milliseconds); pass 0 to ignore.
+func (c *Client) ListUsers(startDate, endDate int64, pageNo, pageSize int) (*UserListResponse, error) {
+	data := c.userForm()
+	data.Set("startDate", strconv.FormatInt(startDate, 10))
+	data.Set("endDate", strconv.FormatInt(endDate, 10))
+	data.Set("pageNo", strconv.Itoa(pageNo))
+	data.Set("pageSize", strconv.Itoa(pageSize))
+
+	var result UserListResponse
+	if err := c.postUserForm("/v3/user/list", data, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ResetUserPassword sets a new password for a user registered by the app.
+// username is the prefixed username returned by RegisterUser.
+// The password should be the plain text password; it will be MD5 hashed automatically.
+func (c *Client) ResetUserPassword(username, password string) error {
+	data := c.userForm()
+	data.Set("username", username)
+	data.Set("password", hashPassword(password))
+
+	var result apiStatus
+	return c.postUserForm("/v3/user/resetPassword", data, &result)
+}
+
+// DeleteUser deletes a user registered by the app.
+// username is the prefixed username returned by RegisterUser.
+// It returns ErrInvalidDeleteUserID for users the app did not register and
+// ErrDeleteOrTransferLocks while the user still has locks.
+func (c *Client) DeleteUser(username string) error {
+	data := c.userForm()
+	data.Set("username", username)
+
+	var result apiStatus
+	return c.postUserForm("/v3/user/delete", data, &result)
+}
+
+// UserIterator allows iterating over users without manually handling pagination
+type UserIterator = Pager[User]
+
+// IterateUsers creates a new iterator for the users registered by the app.
+// startDate and endDate are as in ListUsers.
+func (c *Client) IterateUsers(startDate, endDate int64) *UserIterator {
+	return IterateUsers(c, startDate, endDate)
+}
+
+// IterateUsers creates a new iterator for the users of s, like Client.IterateUsers.
+func IterateUsers(s UserService, startDate, endDate int64) *UserIterator {
+	return NewPager(func(pageNo, pageSize int) ([]User, int, error) {
+		resp, err := s.ListUsers(startDate, endDate, pageNo, pageSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.List, resp.Pages, nil
+	}, func(u User) int { return u.UserID })
+}
+
+// UserFilter holds the optional parameters of the user list.
+type UserFilter struct {
+	StartDate int64 // registration time range in milliseconds; 0 means unbounded
+	EndDate   int64
+	PageSize  int // 0 means DefaultPageSize
+
+	// Concurrency is the number of pages fetched in parallel; 0 or 1 fetches sequentially.
+	Concurrency int
+}
+
+// Users returns a range-over-func iterator over all users registered by the app.
+func (c *Client) Users(ctx context.Context, filter UserFilter) iter.Seq2[User, error] {
+	return Users(ctx, c, filter)
+}
+
+// Users returns a range-over-func iterator over the users of s, like Client.Users.
+func Users(ctx context.Context, s UserService, filter UserFilter) iter.Seq2[User, error] {
+	return IterateUsers(s, filter.StartDate, filter.EndDate).SetPageSize(filter.PageSize).SetConcurrency(filter.Concurrency).All(ctx)
+}
@@ -0,0 +1,50 @@
+package ttlock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/immofon/ttlock"
+	"github.com/immofon/ttlock/ttlocktest"
+)
+
+// countedUsers is a UserService decorator counting list calls
+type countedUsers struct {
+	ttlock.UserService
+	lists int
+}
+
+func (c *countedUsers) ListUsers(startDate, endDate int64, pageNo, pageSize int) (*ttlock.UserListResponse, error) {
+	c.lists++
+	return c.UserService.ListUsers(startDate, endDate, pageNo, pageSize)
+}
+
+func TestUsersThroughService(t *testing.T) {
+	srv := ttlocktest.NewServer()
+	t.Cleanup(srv.Close)
+	app := &ttlock.Client{ClientID: srv.ClientID, ClientSecret: srv.ClientSecret, BaseURL: srv.URL}
+
+	var want []string
+	for _, name := range []string{"u1", "u2", "u3"} {
+		resp, err := app.RegisterUser(name, "pw")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, resp.Username)
+	}
+
+	s := &countedUsers{UserService: app}
+	var got []string
+	for u, err := range ttlock.Users(context.Background(), s, ttlock.UserFilter{PageSize: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, u.Username)
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[2] != want[2] {
+		t.Errorf("users %v, want %v", got, want)
+	}
+	if s.lists != 2 {
+		t.Errorf("%d list calls through the service, want 2", s.lists)
+	}
+}
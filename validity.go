@@ -0,0 +1,137 @@
+package ttlock
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ValidAt reports whether the passcode opens the lock at t, together with a
+// human-readable reason, following the PasscodeType rules.
+// firstUse is the first time the passcode was entered on the lock, or nil if
+// it has never been used; it matters for one-time passcodes and for the
+// use-within-24-hours rule of permanent and period passcodes.
+// Cyclic windows are evaluated in t's location, so t should be expressed in
+// the lock's time zone (see LockDetail.Location).
+func (p *Passcode) ValidAt(t time.Time, firstUse *time.Time) (bool, string) {
+	loc := t.Location()
+	start := time.UnixMilli(p.StartDate).In(loc)
+	end := time.UnixMilli(p.EndDate).In(loc)
+	const layout = "2006-01-02 15:04"
+
+	if t.Before(start) {
+		return false, fmt.Sprintf("not yet valid: starts at %s", start.Format(layout))
+	}
+
+	pwdType := PasscodeType(p.KeyboardPwdType)
+	switch pwdType {
+	case PasscodeTypeOneTime:
+		if firstUse != nil && !t.Before(*firstUse) {
+			return false, fmt.Sprintf("one-time passcode already used at %s", firstUse.In(loc).Format(layout))
+		}
+		deadline := start.Add(6 * time.Hour)
+		if !t.Before(deadline) {
+			return false, fmt.Sprintf("one-time passcode expired at %s (6 hours after start)", deadline.Format(layout))
+		}
+		return true, fmt.Sprintf("one-time passcode usable once until %s", deadline.Format(layout))
+
+	case PasscodeTypePermanent, PasscodeTypePeriod:
+		if pwdType == PasscodeTypePeriod && p.EndDate != 0 && !t.Before(end) {
+			return false, fmt.Sprintf("period passcode expired at %s", end.Format(layout))
+		}
+		activation := start.Add(24 * time.Hour)
+		used := firstUse != nil && firstUse.Before(activation) && !firstUse.Before(start)
+		if !used && !t.Before(activation) {
+			return false, fmt.Sprintf("passcode was not used within 24 hours of start, so it expired at %s", activation.Format(layout))
+		}
+		var window string
+		if pwdType == PasscodeTypePermanent || p.EndDate == 0 {
+			window = fmt.Sprintf("permanent passcode valid since %s", start.Format(layout))
+		} else {
+			window = fmt.Sprintf("period passcode valid until %s", end.Format(layout))
+		}
+		if used {
+			return true, fmt.Sprintf("%s; activated by first use at %s", window, firstUse.In(loc).Format(layout))
+		}
+		return true, fmt.Sprintf("%s; must be used before %s or it expires", window, activation.Format(layout))
+
+	case PasscodeTypeDelete:
+		return false, "delete passcode: entering it clears passcodes previously used on the lock and does not unlock"
+	}
+
+	days, ok := cyclicPasscodeDays[pwdType]
+	if !ok {
+		return false, fmt.Sprintf("unknown passcode type %d", p.KeyboardPwdType)
+	}
+
+	// The hours of start and end carry the daily window; their dates bound the overall validity.
+	// An end at 00:00 closes the window at midnight, so its last day is the day before.
+	endHour := 24
+	if p.EndDate != 0 {
+		lastDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+		if endHour = end.Hour(); endHour == 0 {
+			endHour = 24
+		} else {
+			lastDay = lastDay.AddDate(0, 0, 1)
+		}
+		if !t.Before(lastDay) {
+			return false, fmt.Sprintf("cyclic passcode expired after %s", lastDay.AddDate(0, 0, -1).Format("2006-01-02"))
+		}
+	}
+	if days&(1<<uint(t.Weekday())) == 0 {
+		return false, fmt.Sprintf("cyclic passcode (%s) is not valid on %s", pwdType.cyclicString(), t.Weekday())
+	}
+	if h := t.Hour(); h < start.Hour() || h >= endHour {
+		return false, fmt.Sprintf("cyclic passcode is only valid %02d:00-%02d:00, not at %s", start.Hour(), endHour, t.Format("15:04"))
+	}
+	return true, fmt.Sprintf("cyclic passcode (%s) valid %02d:00-%02d:00", pwdType.cyclicString(), start.Hour(), endHour)
+}
+
+var cyclicPasscodeDays = map[PasscodeType]uint8{
+	PasscodeTypeWeekendCyclic:   weekendDays,
+	PasscodeTypeDailyCyclic:     allDays,
+	PasscodeTypeWorkdayCyclic:   workdayDays,
+	PasscodeTypeMondayCyclic:    1 << time.Monday,
+	PasscodeTypeTuesdayCyclic:   1 << time.Tuesday,
+	PasscodeTypeWednesdayCyclic: 1 << time.Wednesday,
+	PasscodeTypeThursdayCyclic:  1 << time.Thursday,
+	PasscodeTypeFridayCyclic:    1 << time.Friday,
+	PasscodeTypeSaturdayCyclic:  1 << time.Saturday,
+	PasscodeTypeSundayCyclic:    1 << time.Sunday,
+}
+
+func (t PasscodeType) cyclicString() string {
+	switch t {
+	case PasscodeTypeWeekendCyclic:
+		return "weekends"
+	case PasscodeTypeDailyCyclic:
+		return "daily"
+	case PasscodeTypeWorkdayCyclic:
+		return "workdays"
+	}
+	for d, pt := range weekdayPasscodeTypes {
+		if pt == t {
+			return d.String() + "s"
+		}
+	}
+	return "unknown"
+}
+
+// FindPasscode looks up a passcode of a lock by its keyboardPwdId.
+// It returns ErrPasscodeNotExist if no such passcode exists.
+func (c *Client) FindPasscode(lockID, keyboardPwdID int) (*Passcode, error) {
+	return FindPasscode(c, lockID, keyboardPwdID)
+}
+
+// FindPasscode looks up a passcode of s, like Client.FindPasscode.
+func FindPasscode(s PasscodeService, lockID, keyboardPwdID int) (*Passcode, error) {
+	for p, err := range Passcodes(context.Background(), s, lockID, PasscodeFilter{OrderBy: 1}) {
+		if err != nil {
+			return nil, err
+		}
+		if p.KeyboardPwdID == keyboardPwdID {
+			return &p, nil
+		}
+	}
+	return nil, serviceError(s, ErrPasscodeNotExist)
+}
@@ -0,0 +1,75 @@
+package ttlock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPasscodeValidAt(t *testing.T) {
+	loc := time.FixedZone("UTC+1", 3600)
+	at := func(s string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	ms := func(s string) int64 { return at(s).UnixMilli() }
+	used := func(s string) *time.Time { t := at(s); return &t }
+
+	// 2030-11-01 is a Friday
+	tests := []struct {
+		name       string
+		pwdType    PasscodeType
+		start, end string
+		at         string
+		firstUse   *time.Time
+		valid      bool
+	}{
+		{"one-time before start", PasscodeTypeOneTime, "2030-11-01 10:00", "", "2030-11-01 09:00", nil, false},
+		{"one-time unused", PasscodeTypeOneTime, "2030-11-01 10:00", "", "2030-11-01 15:00", nil, true},
+		{"one-time after 6 hours", PasscodeTypeOneTime, "2030-11-01 10:00", "", "2030-11-01 16:00", nil, false},
+		{"one-time used", PasscodeTypeOneTime, "2030-11-01 10:00", "", "2030-11-01 12:00", used("2030-11-01 11:00"), false},
+
+		{"permanent within 24 hours", PasscodeTypePermanent, "2030-11-01 10:00", "", "2030-11-02 09:00", nil, true},
+		{"permanent never used", PasscodeTypePermanent, "2030-11-01 10:00", "", "2030-11-02 10:00", nil, false},
+		{"permanent used in time", PasscodeTypePermanent, "2030-11-01 10:00", "", "2031-05-01 10:00", used("2030-11-01 20:00"), true},
+		{"permanent used late", PasscodeTypePermanent, "2030-11-01 10:00", "", "2030-11-03 10:00", used("2030-11-02 11:00"), false},
+
+		{"period within 24 hours", PasscodeTypePeriod, "2030-11-01 10:00", "2030-11-10 10:00", "2030-11-01 12:00", nil, true},
+		{"period never used", PasscodeTypePeriod, "2030-11-01 10:00", "2030-11-10 10:00", "2030-11-03 12:00", nil, false},
+		{"period used", PasscodeTypePeriod, "2030-11-01 10:00", "2030-11-10 10:00", "2030-11-05 12:00", used("2030-11-01 11:00"), true},
+		{"period expired", PasscodeTypePeriod, "2030-11-01 10:00", "2030-11-10 10:00", "2030-11-10 10:00", used("2030-11-01 11:00"), false},
+
+		{"delete", PasscodeTypeDelete, "2030-11-01 10:00", "", "2030-11-01 11:00", nil, false},
+
+		{"daily in window", PasscodeTypeDailyCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-03 17:59", nil, true},
+		{"daily after window", PasscodeTypeDailyCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-03 18:00", nil, false},
+		{"daily after last day", PasscodeTypeDailyCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-12-01 09:00", nil, false},
+		{"weekend on Saturday", PasscodeTypeWeekendCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-02 09:00", nil, true},
+		{"weekend on Monday", PasscodeTypeWeekendCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-04 09:00", nil, false},
+		{"workday on Monday", PasscodeTypeWorkdayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-04 09:00", nil, true},
+		{"workday on Sunday", PasscodeTypeWorkdayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-03 09:00", nil, false},
+		{"Monday on Monday", PasscodeTypeMondayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-04 09:00", nil, true},
+		{"Tuesday on Tuesday", PasscodeTypeTuesdayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-05 09:00", nil, true},
+		{"Wednesday on Wednesday", PasscodeTypeWednesdayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-06 09:00", nil, true},
+		{"Thursday on Thursday", PasscodeTypeThursdayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-07 09:00", nil, true},
+		{"Friday on Friday", PasscodeTypeFridayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-08 09:00", nil, true},
+		{"Saturday on Saturday", PasscodeTypeSaturdayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-09 09:00", nil, true},
+		{"Sunday on Sunday", PasscodeTypeSundayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-10 09:00", nil, true},
+		{"Sunday on Monday", PasscodeTypeSundayCyclic, "2030-11-01 08:00", "2030-11-30 18:00", "2030-11-11 09:00", nil, false},
+
+		{"midnight end late evening", PasscodeTypeDailyCyclic, "2030-11-01 18:00", "2030-12-01 00:00", "2030-11-30 23:30", nil, true},
+		{"midnight end before window", PasscodeTypeDailyCyclic, "2030-11-01 18:00", "2030-12-01 00:00", "2030-11-30 17:00", nil, false},
+		{"midnight end on the end date", PasscodeTypeDailyCyclic, "2030-11-01 18:00", "2030-12-01 00:00", "2030-12-01 19:00", nil, false},
+	}
+	for _, tt := range tests {
+		p := &Passcode{KeyboardPwdType: int(tt.pwdType), StartDate: ms(tt.start)}
+		if tt.end != "" {
+			p.EndDate = ms(tt.end)
+		}
+		if valid, reason := p.ValidAt(at(tt.at), tt.firstUse); valid != tt.valid {
+			t.Errorf("%s: valid %v (%s), want %v", tt.name, valid, reason, tt.valid)
+		}
+	}
+}